@@ -0,0 +1,199 @@
+package database
+
+import (
+	"math"
+	"statusok/model"
+	"statusok/notify"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultAnomalyZScore         = 3.0
+	DefaultAnomalySustainedCount = 3
+	DefaultAnomalyMinSamples     = 10
+	DefaultAnomalyWindow         = 50
+	DefaultAnomalyMinDeviationMs = 1.0
+
+	hoursPerWeek = 7 * 24
+)
+
+// AnomalyConfig enables baseline based response time alerting for a request.
+// Instead of comparing the median response time against a fixed value, a rolling
+// mean and standard deviation is learned for every hour of the week and a
+// notification is sent when the response time deviates by more than ZScore
+// standard deviations for SustainedCount consecutive requests.
+type AnomalyConfig struct {
+	ZScore         float64 `json:"zScore"`
+	SustainedCount int     `json:"sustainedCount"`
+	MinSamples     int     `json:"minSamples"`
+	Window         int     `json:"window"`
+	MinDeviationMs float64 `json:"minDeviationMs"`
+}
+
+// rolling mean and variance of the response times seen in one hour of the week
+type baseline struct {
+	count    int
+	mean     float64
+	variance float64
+}
+
+type anomalyDetector struct {
+	config    AnomalyConfig
+	baselines [hoursPerWeek]baseline
+	hour      int       // hour of the week of the current deviation
+	deviating int       // number of consecutive deviating response times
+	pending   []float64 // deviating response times held back from the baseline
+	notified  bool      // notification already sent for the current deviation
+}
+
+var (
+	anomalyMutex     sync.Mutex
+	anomalyDetectors map[int]*anomalyDetector // anomaly detectors by request id
+)
+
+func initAnomalyDetectors() {
+	anomalyMutex.Lock()
+	defer anomalyMutex.Unlock()
+
+	anomalyDetectors = make(map[int]*anomalyDetector)
+}
+
+// Use baseline based anomaly detection instead of the expected response time for the given request id
+func EnableAnomalyDetection(id int, config AnomalyConfig) {
+	if config.ZScore <= 0 {
+		config.ZScore = DefaultAnomalyZScore
+	}
+	if config.SustainedCount <= 0 {
+		config.SustainedCount = DefaultAnomalySustainedCount
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = DefaultAnomalyMinSamples
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultAnomalyWindow
+	}
+	if config.MinDeviationMs <= 0 {
+		config.MinDeviationMs = DefaultAnomalyMinDeviationMs
+	}
+
+	anomalyMutex.Lock()
+	defer anomalyMutex.Unlock()
+
+	if anomalyDetectors == nil {
+		anomalyDetectors = make(map[int]*anomalyDetector)
+	}
+	anomalyDetectors[id] = &anomalyDetector{config: config}
+}
+
+func IsAnomalyDetectionEnabled(id int) bool {
+	anomalyMutex.Lock()
+	defer anomalyMutex.Unlock()
+
+	_, ok := anomalyDetectors[id]
+	return ok
+}
+
+// Returns the learned mean and standard deviation of the response time for the hour of the week of t
+func GetResponseTimeBaseline(id int, t time.Time) (mean float64, stdDev float64, samples int) {
+	anomalyMutex.Lock()
+	defer anomalyMutex.Unlock()
+
+	detector, ok := anomalyDetectors[id]
+	if !ok {
+		return 0, 0, 0
+	}
+	b := detector.baselines[hourOfWeek(t)]
+	return b.mean, math.Sqrt(b.variance), b.count
+}
+
+// Compare the response time with the baseline and send a notification when
+// it has been deviating for long enough
+func checkResponseTimeAnomaly(requestInfo model.RequestInfo, t time.Time) {
+	anomalyMutex.Lock()
+	detector, ok := anomalyDetectors[requestInfo.Id]
+	if !ok {
+		anomalyMutex.Unlock()
+		return
+	}
+	sendNotification, mean := detector.observe(t, float64(requestInfo.ResponseTimeMs))
+	anomalyMutex.Unlock()
+
+	if sendNotification {
+		notify.SendResponseTimeNotification(notify.ResponseTimeNotification{
+			Url:                    requestInfo.Url,
+			RequestType:            requestInfo.RequestType,
+			ExpectedResponsetimeMs: int64(math.Round(mean)),
+			MeanResponseTimeMs:     requestInfo.ResponseTimeMs,
+		})
+	}
+}
+
+// Adds the response time to the baseline of its hour of the week.
+// Deviating response times are held back until the deviation is confirmed, so a sustained
+// slowdown cannot raise the baseline it is compared to before the notification is sent.
+// Returns true when a notification should be sent and the baseline mean it was compared to.
+func (detector *anomalyDetector) observe(t time.Time, responseTimeMs float64) (bool, float64) {
+	hour := hourOfWeek(t)
+	if hour != detector.hour {
+		// every hour is compared to its own baseline, a deviation does not continue into the next hour
+		detector.releasePending()
+		detector.deviating = 0
+		detector.hour = hour
+	}
+	b := &detector.baselines[hour]
+	mean := b.mean
+
+	if b.count >= detector.config.MinSamples {
+		stdDev := math.Max(math.Sqrt(b.variance), detector.config.MinDeviationMs)
+		zScore := (responseTimeMs - b.mean) / stdDev
+
+		if zScore <= detector.config.ZScore {
+			detector.releasePending()
+			detector.deviating = 0
+			detector.notified = false
+		} else if !detector.notified {
+			detector.deviating++
+			detector.pending = append(detector.pending, responseTimeMs)
+			if detector.deviating < detector.config.SustainedCount {
+				return false, mean
+			}
+			// once notified the baseline learns the new response times
+			detector.releasePending()
+			detector.notified = true
+			return true, mean
+		}
+	}
+
+	b.add(responseTimeMs, detector.config.Window)
+	return false, mean
+}
+
+// Adds the held back response times to the baseline of the hour of the deviation
+func (detector *anomalyDetector) releasePending() {
+	for _, value := range detector.pending {
+		detector.baselines[detector.hour].add(value, detector.config.Window)
+	}
+	detector.pending = nil
+}
+
+// Update mean and variance. Until window values are collected the exact values are
+// calculated, afterwards older values fade out exponentially.
+func (b *baseline) add(value float64, window int) {
+	if b.count < window {
+		b.count++
+	}
+	alpha := 1 / float64(b.count)
+	if b.count >= window {
+		alpha = 2 / float64(window+1)
+	}
+
+	diff := value - b.mean
+	increment := alpha * diff
+	b.mean = b.mean + increment
+	b.variance = (1 - alpha) * (b.variance + diff*increment)
+}
+
+func hourOfWeek(t time.Time) int {
+	return int(t.Weekday())*24 + t.Hour()
+}
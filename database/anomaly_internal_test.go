@@ -0,0 +1,74 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnomalySustainedDeviation(t *testing.T) {
+	detector := &anomalyDetector{config: AnomalyConfig{
+		ZScore:         DefaultAnomalyZScore,
+		SustainedCount: DefaultAnomalySustainedCount,
+		MinSamples:     DefaultAnomalyMinSamples,
+		Window:         DefaultAnomalyWindow,
+		MinDeviationMs: DefaultAnomalyMinDeviationMs,
+	}}
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		notify, _ := detector.observe(now, float64(90+20*(i%2)))
+		assert.False(t, notify)
+	}
+
+	// deviating response times do not raise the baseline before the notification
+	for i := 1; i < DefaultAnomalySustainedCount; i++ {
+		notify, _ := detector.observe(now, 300)
+		assert.False(t, notify)
+	}
+	b := detector.baselines[hourOfWeek(now)]
+	assert.InDelta(t, 100, b.mean, 0.001)
+	assert.Equal(t, 20, b.count)
+
+	notifications := 0
+	for i := 0; i < 100; i++ {
+		if notify, mean := detector.observe(now, 300); notify {
+			notifications++
+			assert.InDelta(t, 100, mean, 0.001)
+		}
+	}
+	assert.Equal(t, 1, notifications)
+	// the baseline learned the new response time and the deviation ended
+	assert.False(t, detector.notified)
+	assert.Equal(t, 0, detector.deviating)
+
+	for i := 1; i < DefaultAnomalySustainedCount; i++ {
+		notify, _ := detector.observe(now, 3000)
+		assert.False(t, notify)
+	}
+	notify, _ := detector.observe(now, 3000)
+	assert.True(t, notify)
+}
+
+func TestAnomalyShortDeviation(t *testing.T) {
+	detector := &anomalyDetector{config: AnomalyConfig{ZScore: 3, SustainedCount: 3, MinSamples: 10, Window: 50, MinDeviationMs: 1}}
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		detector.observe(now, float64(90+20*(i%2)))
+	}
+
+	// a short spike is added to the baseline when it ends
+	detector.observe(now, 300)
+	assert.Equal(t, 10, detector.baselines[hourOfWeek(now)].count)
+	detector.observe(now, 100)
+	assert.Equal(t, 12, detector.baselines[hourOfWeek(now)].count)
+
+	// a deviation does not continue into the next hour
+	detector.observe(now, 300)
+	detector.observe(now, 300)
+	next := now.Add(time.Hour)
+	detector.observe(next, 100)
+	assert.Equal(t, 0, detector.deviating)
+	assert.Equal(t, 14, detector.baselines[hourOfWeek(now)].count)
+	assert.Equal(t, 1, detector.baselines[hourOfWeek(next)].count)
+}
@@ -0,0 +1,68 @@
+package database_test
+
+import (
+	"statusok/database"
+	"statusok/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseTimeBaseline(t *testing.T) {
+	const requestId = 1
+	ids := make(map[int]int64)
+	ids[requestId] = 10
+
+	t.Cleanup(func() {
+		database.Initialize(make(map[int]int64), 0, 0)
+	})
+
+	database.Initialize(ids, 1, 10)
+	notifications := countNotifications(t)
+
+	assert.False(t, database.IsAnomalyDetectionEnabled(requestId))
+	database.EnableAnomalyDetection(requestId, database.AnomalyConfig{})
+	assert.True(t, database.IsAnomalyDetectionEnabled(requestId))
+
+	for _, responseTime := range []int64{90, 110, 90, 110} {
+		database.AddRequestInfo(model.RequestInfo{
+			Id:                   requestId,
+			Url:                  "http://test.com",
+			RequestType:          "GET",
+			ResponseCode:         200,
+			ResponseTimeMs:       responseTime,
+			ExpectedResponseTime: 10,
+		})
+	}
+
+	mean, stdDev, samples := database.GetResponseTimeBaseline(requestId, time.Now())
+
+	assert.Equal(t, 4, samples)
+	assert.InDelta(t, 100, mean, 0.001)
+	assert.InDelta(t, 10, stdDev, 0.001)
+	// the expected response time is not used when anomaly detection is enabled
+	database.AddRequestInfo(model.RequestInfo{
+		Id:                   requestId,
+		Url:                  "http://test.com",
+		RequestType:          "GET",
+		ResponseCode:         200,
+		ResponseTimeMs:       105,
+		ExpectedResponseTime: 10,
+	})
+	assert.Equal(t, int32(0), notifications())
+}
+
+func TestAnomalyDetectionDisabledByInitialize(t *testing.T) {
+	const requestId = 1
+	ids := make(map[int]int64)
+	ids[requestId] = 10
+
+	database.EnableAnomalyDetection(requestId, database.AnomalyConfig{})
+	database.Initialize(ids, 1, 10)
+
+	assert.False(t, database.IsAnomalyDetectionEnabled(requestId))
+	t.Cleanup(func() {
+		database.Initialize(make(map[int]int64), 0, 0)
+	})
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"fmt"
+	"statusok/model"
+	"statusok/notify"
+	"statusok/state"
+	"strings"
+	"sync"
+)
+
+var (
+	auditMutex    sync.Mutex
+	auditFindings map[string][]string // findings of the last audit by request type and url
+)
+
+func initAuditFindings() {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	auditFindings = make(map[string][]string)
+}
+
+// Loads the findings of the last audits from the state file and saves them there on every change,
+// so findings which are new since StatusOk was stopped are still notified.
+func LoadAuditFindings() error {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	auditFindings = make(map[string][]string)
+	return state.Get(state.SectionAudit, &auditFindings)
+}
+
+// Identifies the audit of a request across restarts, where ids may change with the config
+func auditKey(requestInfo model.RequestInfo) string {
+	return requestInfo.RequestType + " " + requestInfo.Url
+}
+
+// Returns the findings of the audit which the previous audit of the request did not have.
+// The first audit of a request is the baseline and has no new findings.
+func newAuditFindings(key string, findings []string) []string {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	if auditFindings == nil {
+		auditFindings = make(map[string][]string)
+	}
+	previous, audited := auditFindings[key]
+	if !audited || !equalFindings(previous, findings) {
+		auditFindings[key] = findings
+		if err := state.Save(state.SectionAudit, auditFindings); err != nil {
+			fmt.Printf("Saving audit findings to %s failed: %s\n", state.FileName(), err)
+		}
+	}
+	if !audited {
+		return nil
+	}
+
+	known := make(map[string]bool, len(previous))
+	for _, finding := range previous {
+		known[finding] = true
+	}
+	var regressions []string
+	for _, finding := range findings {
+		if !known[finding] {
+			regressions = append(regressions, finding)
+		}
+	}
+	return regressions
+}
+
+func equalFindings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Sends a notification with the findings of the audit of the request which are new since its previous audit
+func checkAuditRegressions(requestInfo model.RequestInfo) {
+	regressions := newAuditFindings(auditKey(requestInfo), requestInfo.AuditFindings)
+	if len(regressions) == 0 {
+		return
+	}
+	notify.SendErrorNotification(notify.ErrorNotification{
+		Url:         requestInfo.Url,
+		RequestType: requestInfo.RequestType,
+		Error:       ErrAudit.Error(),
+		Category:    model.CategoryAuditRegression,
+		OtherInfo:   strings.Join(regressions, "\n"),
+	})
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"statusok/model"
+	"statusok/state"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditFindingsKeptAcrossRestarts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	t.Cleanup(func() {
+		state.Load("")
+		initAuditFindings()
+	})
+	assert.Nil(t, state.Load(filepath.Join(dir, "state.json")))
+	assert.Nil(t, LoadAuditFindings())
+
+	requestInfo := model.RequestInfo{Id: 1, Url: "https://mywebsite.com", RequestType: "GET"}
+	assert.Empty(t, newAuditFindings(auditKey(requestInfo), []string{"csp"}))
+
+	// ids may change with the config
+	assert.Nil(t, state.Load(filepath.Join(dir, "state.json")))
+	assert.Nil(t, LoadAuditFindings())
+	requestInfo.Id = 2
+	assert.Equal(t, []string{"hsts"}, newAuditFindings(auditKey(requestInfo), []string{"csp", "hsts"}))
+	assert.Empty(t, newAuditFindings(auditKey(requestInfo), []string{"csp", "hsts"}))
+}
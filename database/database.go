@@ -6,9 +6,11 @@ import (
 	"reflect"
 	"sort"
 	"statusok/logger"
+	"statusok/maintenance"
 	"statusok/model"
 	"statusok/notify"
 	"strings"
+	"time"
 )
 
 var (
@@ -22,6 +24,21 @@ var (
 	ErrTimeout       = errors.New("Request Time out Error")
 	ErrCreateRequest = errors.New("Invalid Request Config. Not able to create request")
 	ErrDoRequest     = errors.New("Request failed")
+	ErrReadResponse  = errors.New("Reading the response failed")
+	ErrTokenFetch    = errors.New("Fetching the authentication token failed")
+	ErrAuth          = errors.New("Authentication failed")
+	ErrQuery         = errors.New("Query failed")
+	ErrCertExpiry    = errors.New("Certificate expires soon")
+	ErrClockSkew     = errors.New("Clock offset or stratum exceeds the threshold")
+	ErrContentChange = errors.New("Content changed")
+	ErrContentStale  = errors.New("Content did not change")
+	ErrAudit         = errors.New("Security audit has new findings")
+	ErrBrokenLinks   = errors.New("Broken links found")
+	ErrProxy         = errors.New("Connecting through the proxy failed")
+	ErrCheckWarning  = errors.New("Check returned WARNING")
+	ErrCheckCritical = errors.New("Check returned CRITICAL")
+	ErrCheckUnknown  = errors.New("Check returned UNKNOWN")
+	ErrFlapping      = errors.New("Request is flapping between failure and success")
 )
 
 type Database interface {
@@ -29,6 +46,7 @@ type Database interface {
 	GetDatabaseName() string
 	AddRequestInfo(requestInfo model.RequestInfo) error
 	AddErrorInfo(errorInfo model.ErrorInfo) error
+	AddResultInfo(resultInfo model.ResultInfo) error
 	IsEmpty() bool
 }
 
@@ -51,6 +69,10 @@ func Initialize(ids map[int]int64, mMinResponseCount int, mErrorCount int) {
 	}
 	// TODO: try to make all slices as pointers or adapt Storage
 	initResponseQueue()
+	initAnomalyDetectors()
+	initCheckStates()
+	initDependencies()
+	initAuditFindings()
 
 	for id := range ids {
 		queue := make([]int64, 0)
@@ -118,7 +140,7 @@ func addTestErrorAndRequestInfo(db Database) error {
 		return fmt.Errorf("InfluxDB: Failed to insert Request data to database %s, error: %s. Please check whether database is installed properly!", db.GetDatabaseName(), reqErr)
 	}
 
-	errorInfo := model.ErrorInfo{Id: 0, Url: "http://test.com", RequestType: "GET", ResponseCode: 0, ResponseBody: "test response", Reason: errors.New("test error"), OtherInfo: "test other info"}
+	errorInfo := model.ErrorInfo{Id: 0, Url: "http://test.com", RequestType: "GET", ResponseCode: 0, ResponseBody: "test response", Reason: errors.New("test error"), Category: model.CategoryRequestFailed, OtherInfo: "test other info"}
 
 	if errErr := db.AddErrorInfo(errorInfo); errErr != nil {
 		return fmt.Errorf("InfluxDB: Failed to insert Error data to database %s, error: %s. Please check whether database is installed properly!", db.GetDatabaseName(), errErr)
@@ -129,6 +151,11 @@ func addTestErrorAndRequestInfo(db Database) error {
 // This function is called by requests package when request has been successfully performed
 // Request data is inserted to all the registered databases
 func AddRequestInfo(requestInfo model.RequestInfo) {
+	startedFlapping, _ := recordResult(requestInfo.Id, requestInfo.Url, true)
+	requestInfo.Flapping = IsFlapping(requestInfo.Id)
+	requestInfo.Maintenance = maintenance.IsActive(requestInfo.Id, time.Now())
+	_, parentDown := getDownParent(requestInfo.Id)
+
 	logger.LogRequestInfo(requestInfo)
 
 	// Response time to queue
@@ -139,6 +166,26 @@ func AddRequestInfo(requestInfo model.RequestInfo) {
 		go db.AddRequestInfo(requestInfo)
 	}
 
+	// A request can also start flapping with a success, failures are not notified from then on
+	if startedFlapping && !requestInfo.Maintenance && !parentDown {
+		sendFlappingNotification(notify.ErrorNotification{Url: requestInfo.Url, RequestType: requestInfo.RequestType}, "Last request succeeded.")
+	}
+
+	// No alerts until the request is stable again or during maintenance
+	if requestInfo.Flapping || requestInfo.Maintenance {
+		return
+	}
+
+	if requestInfo.AuditFindings != nil {
+		checkAuditRegressions(requestInfo)
+	}
+
+	// Compare with the learned baseline instead of the expected response time
+	if IsAnomalyDetectionEnabled(requestInfo.Id) {
+		checkResponseTimeAnomaly(requestInfo, time.Now())
+		return
+	}
+
 	if CountResponsesInQueue(requestInfo.Id) < MinResponseCount {
 		return
 	}
@@ -161,23 +208,68 @@ func AddRequestInfo(requestInfo model.RequestInfo) {
 }
 
 // This function is called by requests package when a reuquest fails
-// Error Information is inserted to all the registered databases
-func AddErrorInfo(errorInfo model.ErrorInfo) {
+// Error Information is inserted to all the registered databases.
+// Returns whether a notification was sent, it is suppressed during maintenance, while a parent is down and while flapping.
+func AddErrorInfo(errorInfo model.ErrorInfo) bool {
+	startedFlapping, _ := recordResult(errorInfo.Id, errorInfo.Url, false)
+	errorInfo.Flapping = IsFlapping(errorInfo.Id)
+	errorInfo.Maintenance = maintenance.IsActive(errorInfo.Id, time.Now())
+	parentUrl, parentDown := getDownParent(errorInfo.Id)
+	if parentDown {
+		errorInfo.OtherInfo = strings.TrimSpace(errorInfo.OtherInfo + " Notification suppressed, depends on request which is down or flapping: " + parentUrl)
+	}
+
 	logger.LogErrorInfo(errorInfo)
 
-	// Request failed send notification
-	notify.SendErrorNotification(notify.ErrorNotification{
-		Url:          errorInfo.Url,
-		RequestType:  errorInfo.RequestType,
-		ResponseBody: errorInfo.ResponseBody,
-		Error:        errorInfo.Reason.Error(),
-		OtherInfo:    errorInfo.OtherInfo,
-	})
+	notified := false
+	switch {
+	case errorInfo.Maintenance:
+		// Only save the error during maintenance
+	case parentDown:
+		// Folded into the notification of the request it depends on
+	case startedFlapping:
+		sendFlappingNotification(notify.ErrorNotification{
+			Url:          errorInfo.Url,
+			RequestType:  errorInfo.RequestType,
+			ResponseBody: errorInfo.ResponseBody,
+			Category:     errorInfo.Category,
+		}, fmt.Sprintf("Last error: %s.", errorInfo.Reason.Error()))
+		notified = true
+	case !errorInfo.Flapping:
+		// Request failed send notification
+		notify.SendErrorNotification(notify.ErrorNotification{
+			Url:          errorInfo.Url,
+			RequestType:  errorInfo.RequestType,
+			ResponseBody: errorInfo.ResponseBody,
+			Error:        errorInfo.Reason.Error(),
+			Category:     errorInfo.Category,
+			OtherInfo:    errorInfo.OtherInfo,
+		})
+		notified = true
+	}
 
 	// Add Error information to database
 	for _, db := range dbList {
 		go db.AddErrorInfo(errorInfo)
 	}
+	return notified
+}
+
+// Send a single notification when a request starts flapping and suppress further ones until it is stable again
+func sendFlappingNotification(notification notify.ErrorNotification, lastResult string) {
+	notification.Error = ErrFlapping.Error()
+	notification.OtherInfo = lastResult + " Further notifications are suppressed until the request is stable again."
+	notify.SendErrorNotification(notification)
+}
+
+// This function is called by requests package for every attempt to perform a request, successful or not
+// The result is inserted to all the registered databases
+func AddResultInfo(resultInfo model.ResultInfo) {
+	resultInfo.Maintenance = maintenance.IsActive(resultInfo.Id, time.Now())
+
+	for _, db := range dbList {
+		go db.AddResultInfo(resultInfo)
+	}
 }
 
 func initResponseQueue() {
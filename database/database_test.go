@@ -3,14 +3,36 @@ package database_test
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"statusok/database"
+	"statusok/maintenance"
 	"statusok/mocks"
 	"statusok/model"
+	"statusok/notify"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// registers a http notification client and returns the number of notifications it received
+func countNotifications(t *testing.T) func() int32 {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+	}))
+	notify.AddNew(notify.NotificationTypes{Http: notify.HttpNotify{Url: server.URL, RequestType: http.MethodPost}})
+	t.Cleanup(func() {
+		notify.ResetNotifications()
+		server.Close()
+	})
+	return func() int32 {
+		return atomic.LoadInt32(&count)
+	}
+}
+
 func TestInitialize(t *testing.T) {
 	ids := make(map[int]int64)
 	ids[1] = 10
@@ -224,6 +246,22 @@ func TestAddRequestAndErrorInfo(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestAddErrorInfoNotified(t *testing.T) {
+	t.Cleanup(func() {
+		database.Initialize(make(map[int]int64), 0, 0)
+		maintenance.Initialize(nil)
+	})
+	database.Initialize(map[int]int64{1: 10}, 0, 0)
+
+	errorInfo := model.ErrorInfo{Id: 1, Url: "http://test.com", RequestType: "GET", Reason: errors.New("test error"), Category: model.CategoryContentChanged}
+	assert.True(t, database.AddErrorInfo(errorInfo))
+
+	// no notification is sent during maintenance
+	now := time.Now()
+	assert.Nil(t, maintenance.Initialize([]maintenance.Window{{Start: now.Add(-time.Hour).Format(time.RFC3339), End: now.Add(time.Hour).Format(time.RFC3339)}}))
+	assert.False(t, database.AddErrorInfo(errorInfo))
+}
+
 func TestNotify(t *testing.T) {
 	const minRequestCount = 2
 	const requestId = 1
@@ -0,0 +1,42 @@
+package database
+
+import "sync"
+
+var (
+	dependencyMutex sync.RWMutex
+	dependencies    map[int][]int // ids of the requests a request depends on
+)
+
+func initDependencies() {
+	dependencyMutex.Lock()
+	defer dependencyMutex.Unlock()
+
+	dependencies = make(map[int][]int)
+}
+
+// Set the requests the given request depends on. Notifications for errors of the
+// request are suppressed while one of them is down or flapping.
+func SetDependencies(id int, parentIds []int) {
+	dependencyMutex.Lock()
+	defer dependencyMutex.Unlock()
+
+	if dependencies == nil {
+		dependencies = make(map[int][]int)
+	}
+	dependencies[id] = parentIds
+}
+
+// Returns the url of a request the given request depends on which is currently down.
+// A flapping request counts as down, its failures cause the errors of the requests depending on it as well.
+func getDownParent(id int) (string, bool) {
+	dependencyMutex.RLock()
+	parentIds := dependencies[id]
+	dependencyMutex.RUnlock()
+
+	for _, parentId := range parentIds {
+		if status := GetCheckStatus(parentId); status == StatusDown || status == StatusFlapping {
+			return getCheckUrl(parentId), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"errors"
+	"statusok/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownParent(t *testing.T) {
+	const parentId, childId = 1, 2
+	t.Cleanup(func() {
+		DisableFlapDetection()
+		Initialize(make(map[int]int64), 0, 0)
+	})
+	Initialize(map[int]int64{parentId: 200, childId: 200}, 10, 10)
+	EnableFlapDetection(FlapDetection{Window: 5, HighThreshold: 0.5, LowThreshold: 0.25})
+	SetDependencies(childId, []int{parentId})
+
+	addParentResult := func(success bool) {
+		if success {
+			AddRequestInfo(model.RequestInfo{Id: parentId, Url: "http://parent.com", RequestType: "GET", ResponseCode: 200, ResponseTimeMs: 10, ExpectedResponseTime: 200})
+		} else {
+			AddErrorInfo(model.ErrorInfo{Id: parentId, Url: "http://parent.com", RequestType: "GET", Reason: errors.New("test error")})
+		}
+	}
+
+	addParentResult(true)
+	_, down := getDownParent(childId)
+	assert.False(t, down)
+
+	addParentResult(false)
+	url, down := getDownParent(childId)
+	assert.True(t, down)
+	assert.Equal(t, "http://parent.com", url)
+
+	// a flapping parent suppresses the notifications of its children even while it is up
+	for _, success := range []bool{true, false, true, true} {
+		addParentResult(success)
+	}
+	assert.Equal(t, StatusFlapping, GetCheckStatus(parentId))
+	_, down = getDownParent(childId)
+	assert.True(t, down)
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"statusok/logger"
+	"sync"
+)
+
+const (
+	StatusUnknown  = "UNKNOWN"
+	StatusUp       = "UP"
+	StatusDown     = "DOWN"
+	StatusFlapping = "FLAPPING"
+
+	DefaultFlapWindow        = 10
+	DefaultFlapHighThreshold = 0.5
+	DefaultFlapLowThreshold  = 0.25
+)
+
+// FlapDetection configures when a check alternating between failure and success is marked as flapping.
+// Thresholds are the share of state changes within the last Window results.
+type FlapDetection struct {
+	Window        int     `json:"window"`
+	HighThreshold float64 `json:"highThreshold"`
+	LowThreshold  float64 `json:"lowThreshold"`
+}
+
+// state of a check derived from its latest results
+type checkState struct {
+	url      string
+	results  []bool // latest results, true for a successful request
+	flapping bool
+}
+
+var (
+	stateMutex    sync.Mutex
+	checkStates   map[int]*checkState // check states by request id
+	flapDetection *FlapDetection      // nil when flap detection is disabled
+)
+
+func initCheckStates() {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	checkStates = make(map[int]*checkState)
+}
+
+// Enable flap detection for all requests
+func EnableFlapDetection(config FlapDetection) {
+	if config.Window <= 1 {
+		config.Window = DefaultFlapWindow
+	}
+	if config.HighThreshold <= 0 {
+		config.HighThreshold = DefaultFlapHighThreshold
+	}
+	if config.LowThreshold <= 0 || config.LowThreshold > config.HighThreshold {
+		config.LowThreshold = DefaultFlapLowThreshold
+	}
+
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	flapDetection = &config
+}
+
+func DisableFlapDetection() {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	flapDetection = nil
+}
+
+// Returns UP, DOWN or FLAPPING depending on the latest results of the request, UNKNOWN if it was not performed yet
+func GetCheckStatus(id int) string {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	state, ok := checkStates[id]
+	if !ok || len(state.results) == 0 {
+		return StatusUnknown
+	}
+	if state.flapping {
+		return StatusFlapping
+	}
+	if state.results[len(state.results)-1] {
+		return StatusUp
+	}
+	return StatusDown
+}
+
+func getCheckUrl(id int) string {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	if state, ok := checkStates[id]; ok {
+		return state.url
+	}
+	return ""
+}
+
+func IsFlapping(id int) bool {
+	return GetCheckStatus(id) == StatusFlapping
+}
+
+// Add the result of a request to its state and update the flapping state.
+// Returns whether the request started or stopped flapping with this result.
+func recordResult(id int, url string, success bool) (started bool, stopped bool) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	if checkStates == nil {
+		checkStates = make(map[int]*checkState)
+	}
+	state, ok := checkStates[id]
+	if !ok {
+		state = &checkState{url: url}
+		checkStates[id] = state
+	}
+
+	window := 1
+	if flapDetection != nil {
+		window = flapDetection.Window
+	}
+	state.results = append(state.results, success)
+	if len(state.results) > window {
+		state.results = state.results[len(state.results)-window:]
+	}
+
+	if flapDetection == nil || len(state.results) < window {
+		return false, false
+	}
+
+	ratio := stateChangeRatio(state.results)
+
+	if !state.flapping && ratio >= flapDetection.HighThreshold {
+		state.flapping = true
+		logger.LogFlapping(id, url, true, ratio)
+		return true, false
+	}
+	if state.flapping && ratio <= flapDetection.LowThreshold {
+		state.flapping = false
+		logger.LogFlapping(id, url, false, ratio)
+		return false, true
+	}
+	return false, false
+}
+
+// share of consecutive results which differ from each other
+func stateChangeRatio(results []bool) float64 {
+	if len(results) < 2 {
+		return 0
+	}
+	changes := 0
+	for i := 1; i < len(results); i++ {
+		if results[i] != results[i-1] {
+			changes++
+		}
+	}
+	return float64(changes) / float64(len(results)-1)
+}
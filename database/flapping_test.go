@@ -0,0 +1,117 @@
+package database_test
+
+import (
+	"errors"
+	"statusok/database"
+	"statusok/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func addResult(requestId int, success bool) {
+	if success {
+		database.AddRequestInfo(model.RequestInfo{
+			Id:                   requestId,
+			Url:                  "http://test.com",
+			RequestType:          "GET",
+			ResponseCode:         200,
+			ResponseTimeMs:       10,
+			ExpectedResponseTime: 200,
+		})
+		return
+	}
+	database.AddErrorInfo(model.ErrorInfo{
+		Id:           requestId,
+		Url:          "http://test.com",
+		RequestType:  "GET",
+		ResponseCode: 500,
+		ResponseBody: "test response",
+		Reason:       errors.New("test error"),
+	})
+}
+
+func TestCheckStatus(t *testing.T) {
+	const requestId = 1
+	ids := make(map[int]int64)
+	ids[requestId] = 200
+
+	t.Cleanup(func() {
+		database.Initialize(make(map[int]int64), 0, 0)
+	})
+
+	database.Initialize(ids, 10, 10)
+
+	assert.Equal(t, database.StatusUnknown, database.GetCheckStatus(requestId))
+	addResult(requestId, false)
+	assert.Equal(t, database.StatusDown, database.GetCheckStatus(requestId))
+	addResult(requestId, true)
+	assert.Equal(t, database.StatusUp, database.GetCheckStatus(requestId))
+}
+
+func TestFlapDetection(t *testing.T) {
+	const requestId = 1
+	ids := make(map[int]int64)
+	ids[requestId] = 200
+
+	t.Cleanup(func() {
+		database.DisableFlapDetection()
+		database.Initialize(make(map[int]int64), 0, 0)
+	})
+
+	database.Initialize(ids, 10, 10)
+	database.EnableFlapDetection(database.FlapDetection{Window: 5, HighThreshold: 0.5, LowThreshold: 0.25})
+
+	t.Run("not flapping until the window is full", func(t *testing.T) {
+		addResult(requestId, true)
+		addResult(requestId, false)
+		addResult(requestId, true)
+		addResult(requestId, false)
+		assert.False(t, database.IsFlapping(requestId))
+	})
+
+	t.Run("flapping when state changes exceed high threshold", func(t *testing.T) {
+		addResult(requestId, true)
+		assert.True(t, database.IsFlapping(requestId))
+		assert.Equal(t, database.StatusFlapping, database.GetCheckStatus(requestId))
+	})
+
+	t.Run("still flapping above low threshold", func(t *testing.T) {
+		addResult(requestId, true)
+		addResult(requestId, true)
+		assert.True(t, database.IsFlapping(requestId))
+	})
+
+	t.Run("stable again below low threshold", func(t *testing.T) {
+		addResult(requestId, true)
+		assert.False(t, database.IsFlapping(requestId))
+		assert.Equal(t, database.StatusUp, database.GetCheckStatus(requestId))
+	})
+}
+
+func TestFlappingNotification(t *testing.T) {
+	const requestId = 1
+	t.Cleanup(func() {
+		database.DisableFlapDetection()
+		database.Initialize(make(map[int]int64), 0, 0)
+	})
+	database.Initialize(map[int]int64{requestId: 200}, 10, 10)
+	database.EnableFlapDetection(database.FlapDetection{Window: 5, HighThreshold: 0.5, LowThreshold: 0.25})
+	notifications := countNotifications(t)
+
+	addResult(requestId, true)
+	addResult(requestId, false)
+	addResult(requestId, true)
+	addResult(requestId, false)
+	assert.Equal(t, int32(2), notifications())
+
+	// a success starts flapping and sends the single notification
+	addResult(requestId, true)
+	assert.True(t, database.IsFlapping(requestId))
+	assert.Equal(t, int32(3), notifications())
+
+	// failures while flapping are not notified
+	addResult(requestId, false)
+	addResult(requestId, true)
+	assert.Equal(t, int32(3), notifications())
+}
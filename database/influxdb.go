@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 	"statusok/model"
 	"strconv"
+	"strings"
 	"time"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
@@ -23,7 +25,8 @@ type InfluxDb struct {
 var influxDBcon influxdb2.Client
 
 const (
-	DatabaseName = "InfluxDB"
+	DatabaseName       = "InfluxDB"
+	ResultsMeasurement = "results"
 )
 
 // Return database name
@@ -78,11 +81,15 @@ func (influxDb *InfluxDb) AddRequestInfo(requestInfo model.RequestInfo) error {
 	tags := map[string]string{
 		"requestId":   strconv.Itoa(requestInfo.Id),
 		"requestType": requestInfo.RequestType,
+		"maintenance": strconv.FormatBool(requestInfo.Maintenance),
 	}
 	fields := map[string]interface{}{
 		"responseTimeMs": requestInfo.ResponseTimeMs,
 		"responseCode":   requestInfo.ResponseCode,
+		"flapping":       requestInfo.Flapping,
+		"attempts":       requestInfo.Attempts,
 	}
+	addMetrics(fields, requestInfo.Metrics)
 
 	writeAPI := influxDBcon.WriteAPIBlocking(influxDb.Org, influxDb.Bucket)
 
@@ -108,12 +115,20 @@ func (influxDb *InfluxDb) AddErrorInfo(errorInfo model.ErrorInfo) error {
 		"requestId":   strconv.Itoa(errorInfo.Id),
 		"requestType": errorInfo.RequestType,
 		"reason":      errorInfo.Reason.Error(),
+		"category":    errorInfo.Category,
+		"maintenance": strconv.FormatBool(errorInfo.Maintenance),
 	}
 	fields := map[string]interface{}{
-		"responseBody": errorInfo.ResponseBody,
-		"responseCode": errorInfo.ResponseCode,
-		"otherInfo":    errorInfo.OtherInfo,
+		"responseBody":   errorInfo.ResponseBody,
+		"responseCode":   errorInfo.ResponseCode,
+		"responseTimeMs": errorInfo.ResponseTimeMs,
+		"headers":        formatHeaders(errorInfo.Headers),
+		"otherInfo":      errorInfo.OtherInfo,
+		"step":           errorInfo.Step,
+		"flapping":       errorInfo.Flapping,
+		"attempts":       errorInfo.Attempts,
 	}
+	addMetrics(fields, errorInfo.Metrics)
 
 	writeAPI := influxDBcon.WriteAPIBlocking(influxDb.Org, influxDb.Bucket)
 
@@ -133,6 +148,84 @@ func (influxDb *InfluxDb) AddErrorInfo(errorInfo model.ErrorInfo) error {
 	return nil
 }
 
+// Add the result of a single attempt to database. All results are saved to the
+// measurement "results" so successful and failed requests form one time series
+func (influxDb *InfluxDb) AddResultInfo(resultInfo model.ResultInfo) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tags := map[string]string{
+		"requestId":   strconv.Itoa(resultInfo.Id),
+		"url":         resultInfo.Url,
+		"requestType": resultInfo.RequestType,
+		"success":     strconv.FormatBool(resultInfo.Success),
+		"category":    resultInfo.Category,
+		"maintenance": strconv.FormatBool(resultInfo.Maintenance),
+	}
+	fields := map[string]interface{}{
+		"responseCode":   resultInfo.ResponseCode,
+		"responseTimeMs": resultInfo.ResponseTimeMs,
+		"headers":        formatHeaders(resultInfo.Headers),
+		"attempt":        resultInfo.Attempt,
+	}
+	addMetrics(fields, resultInfo.Metrics)
+
+	writeAPI := influxDBcon.WriteAPIBlocking(influxDb.Org, influxDb.Bucket)
+
+	p := influxdb2.NewPoint(ResultsMeasurement, tags, fields, time.Now())
+
+	err := writeAPI.WritePoint(ctx, p)
+	if err != nil {
+		fmt.Printf("Influxdb: could not write result to db, error: %s\n", err)
+		return err
+	}
+	return nil
+}
+
+// fields and tags saved for every request, metrics of checks cannot replace them
+var reservedFields = map[string]bool{
+	"requestId":      true,
+	"url":            true,
+	"requestType":    true,
+	"success":        true,
+	"category":       true,
+	"reason":         true,
+	"maintenance":    true,
+	"responseTimeMs": true,
+	"responseCode":   true,
+	"responseBody":   true,
+	"headers":        true,
+	"otherInfo":      true,
+	"step":           true,
+	"flapping":       true,
+	"attempts":       true,
+	"attempt":        true,
+}
+
+// Tells whether a metric has the name of a field or tag saved for every request
+func IsReservedMetric(name string) bool {
+	return reservedFields[name]
+}
+
+// metrics of a check are saved as fields with their name, metrics named like a reserved field are dropped
+func addMetrics(fields map[string]interface{}, metrics map[string]float64) {
+	for name, value := range metrics {
+		if !IsReservedMetric(name) {
+			fields[name] = value
+		}
+	}
+}
+
+// headers as sorted "key: value" lines
+func formatHeaders(headers map[string]string) string {
+	lines := make([]string, 0, len(headers))
+	for key, value := range headers {
+		lines = append(lines, key+": "+value)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
 // Returns mean response time of url in given time .Currentlt not used
 func (influxDb *InfluxDb) GetMeanResponseTime(Url string, span int) (float64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
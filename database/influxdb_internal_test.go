@@ -0,0 +1,21 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddMetrics(t *testing.T) {
+	fields := map[string]interface{}{
+		"responseTimeMs": int64(120),
+		"responseCode":   200,
+	}
+	addMetrics(fields, map[string]float64{"handshakeMs": 12, "responseTimeMs": 9999, "flapping": 1, "category": 1})
+
+	assert.Equal(t, map[string]interface{}{
+		"responseTimeMs": int64(120),
+		"responseCode":   200,
+		"handshakeMs":    12.0,
+	}, fields)
+}
@@ -32,13 +32,20 @@ func EnableLogging(fileName string) {
 func LogErrorInfo(errorInfo model.ErrorInfo) {
 	if isLoggingEnabled {
 		logrus.WithFields(logrus.Fields{
-			"id":           errorInfo.Id,
-			"url":          errorInfo.Url,
-			"requestType":  errorInfo.RequestType,
-			"responseCode": errorInfo.ResponseCode,
-			"responseBody": errorInfo.ResponseBody,
-			"reason":       errorInfo.Reason.Error(),
-			"otherInfo":    errorInfo.Reason,
+			"id":             errorInfo.Id,
+			"url":            errorInfo.Url,
+			"requestType":    errorInfo.RequestType,
+			"responseCode":   errorInfo.ResponseCode,
+			"responseTimeMs": errorInfo.ResponseTimeMs,
+			"responseBody":   errorInfo.ResponseBody,
+			"reason":         errorInfo.Reason.Error(),
+			"category":       errorInfo.Category,
+			"step":           errorInfo.Step,
+			"metrics":        errorInfo.Metrics,
+			"otherInfo":      errorInfo.Reason,
+			"flapping":       errorInfo.Flapping,
+			"maintenance":    errorInfo.Maintenance,
+			"attempts":       errorInfo.Attempts,
 		}).Error("Status Ok Error occurred for url " + errorInfo.Url)
 	}
 }
@@ -52,6 +59,44 @@ func LogRequestInfo(requestInfo model.RequestInfo) {
 			"responseCode":         requestInfo.ResponseCode,
 			"responseTimeMs":       requestInfo.ResponseTimeMs,
 			"expectedResponseTime": requestInfo.ExpectedResponseTime,
+			"metrics":              requestInfo.Metrics,
+			"auditFindings":        requestInfo.AuditFindings,
+			"flapping":             requestInfo.Flapping,
+			"maintenance":          requestInfo.Maintenance,
+			"attempts":             requestInfo.Attempts,
 		}).Info("")
 	}
 }
+
+// Log a failed attempt of a request which is retried
+func LogRetry(errorInfo model.ErrorInfo, attempt int, responseTimeMs int64) {
+	if isLoggingEnabled {
+		logrus.WithFields(logrus.Fields{
+			"id":             errorInfo.Id,
+			"url":            errorInfo.Url,
+			"requestType":    errorInfo.RequestType,
+			"responseCode":   errorInfo.ResponseCode,
+			"reason":         errorInfo.Reason.Error(),
+			"category":       errorInfo.Category,
+			"otherInfo":      errorInfo.OtherInfo,
+			"attempt":        attempt,
+			"responseTimeMs": responseTimeMs,
+		}).Warn("Status Ok retrying request for url " + errorInfo.Url)
+	}
+}
+
+func LogFlapping(id int, url string, flapping bool, stateChangeRatio float64) {
+	if isLoggingEnabled {
+		entry := logrus.WithFields(logrus.Fields{
+			"id":               id,
+			"url":              url,
+			"flapping":         flapping,
+			"stateChangeRatio": stateChangeRatio,
+		})
+		if flapping {
+			entry.Warn("Status Ok request started flapping for url " + url)
+		} else {
+			entry.Info("Status Ok request is stable again for url " + url)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package maintenance
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Http api to manage maintenance windows, requests have to send the token as bearer token.
+// GET lists the windows, POST creates a window from the json body
+// and DELETE removes the window given by the id parameter
+func HttpHandler(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="maintenance"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handle(w, r)
+	}
+}
+
+// Tells whether the request has the token as bearer token, an empty token authorizes nobody
+func authorized(r *http.Request, token string) bool {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(token) == 0 || len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(header[len(prefix):]), []byte(token)) == 1
+}
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJson(w, http.StatusOK, List(time.Now()))
+	case http.MethodPost:
+		var window Window
+		if err := json.NewDecoder(r.Body).Decode(&window); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid maintenance window: %s", err), http.StatusBadRequest)
+			return
+		}
+		window, err := Add(window)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid maintenance window: %s", err), http.StatusBadRequest)
+			return
+		}
+		writeJson(w, http.StatusCreated, window)
+	case http.MethodDelete:
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+		if err := Remove(id); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
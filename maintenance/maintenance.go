@@ -0,0 +1,224 @@
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"statusok/schedule"
+	"sync"
+	"time"
+)
+
+// Window is a period in which requests are still performed and saved, but no notifications are sent.
+// It is either a one-off window from Start to End or a recurring window starting at Cron or RRule and lasting Duration.
+// It applies to the checks and groups given or to all requests if none are given.
+type Window struct {
+	Id       int      `json:"id"`
+	Name     string   `json:"name"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Cron     string   `json:"cron"`
+	RRule    string   `json:"rrule"`
+	Duration string   `json:"duration"`
+	Timezone string   `json:"timezone"`
+	Groups   []string `json:"groups"`
+	Checks   []string `json:"checks"`
+
+	start    time.Time
+	end      time.Time
+	schedule *schedule.Schedule
+	duration time.Duration
+}
+
+// Check identifies a monitored request for matching it with the windows
+type Check struct {
+	Id    int
+	Name  string
+	Group string
+	Url   string
+}
+
+var (
+	mutex   sync.RWMutex
+	windows []*Window
+	checks  = make(map[int]Check)
+	nextId  = 1
+
+	ErrWindowNotFound = errors.New("Maintenance window not found")
+)
+
+// Validate and set the maintenance windows given in the config file
+func Initialize(configWindows []Window) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	windows = []*Window{}
+	nextId = 1
+
+	for i := range configWindows {
+		window := configWindows[i]
+		if err := window.Validate(); err != nil {
+			return fmt.Errorf("Invalid maintenance window #%d %s: %s", i, window.Name, err)
+		}
+		window.Id = nextId
+		nextId++
+		windows = append(windows, &window)
+	}
+	return nil
+}
+
+// Register a request so windows can be matched by its name, group or url
+func RegisterCheck(check Check) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	checks[check.Id] = check
+}
+
+// Add a window, e.g. an ad-hoc silence created through the http api.
+func Add(window Window) (Window, error) {
+	if err := window.Validate(); err != nil {
+		return window, err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	window.Id = nextId
+	nextId++
+	windows = append(windows, &window)
+	return window, nil
+}
+
+func Remove(id int) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for i, window := range windows {
+		if window.Id == id {
+			windows = append(windows[:i], windows[i+1:]...)
+			return nil
+		}
+	}
+	return ErrWindowNotFound
+}
+
+// Returns all windows which did not end yet, ordered by id
+func List(now time.Time) []Window {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	list := make([]Window, 0, len(windows))
+	remaining := windows[:0]
+	for _, window := range windows {
+		// Drop expired one-off windows
+		if window.schedule == nil && !now.Before(window.end) {
+			continue
+		}
+		remaining = append(remaining, window)
+		list = append(list, *window)
+	}
+	windows = remaining
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list
+}
+
+// Tells whether a maintenance window applying to the request is active at the given time
+func IsActive(id int, t time.Time) bool {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	check, registered := checks[id]
+	for _, window := range windows {
+		if window.appliesTo(check, registered) && window.activeAt(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check whether the window is either one-off or recurring and parse its times
+func (window *Window) Validate() error {
+	location := time.Local
+	if len(window.Timezone) != 0 {
+		var err error
+		if location, err = time.LoadLocation(window.Timezone); err != nil {
+			return fmt.Errorf("Invalid timezone %s", window.Timezone)
+		}
+	}
+
+	recurring := len(window.Cron) != 0 || len(window.RRule) != 0
+	oneOff := len(window.Start) != 0 || len(window.End) != 0
+
+	switch {
+	case recurring && oneOff:
+		return errors.New("Either start and end or cron/rrule and duration can be given")
+	case len(window.Cron) != 0 && len(window.RRule) != 0:
+		return errors.New("Either cron or rrule can be given")
+	case recurring:
+		var err error
+		if len(window.Cron) != 0 {
+			window.schedule, err = schedule.Parse(window.Cron, location)
+		} else {
+			window.schedule, err = schedule.ParseRRule(window.RRule, location)
+		}
+		if err != nil {
+			return err
+		}
+		if window.duration, err = time.ParseDuration(window.Duration); err != nil || window.duration <= 0 {
+			return fmt.Errorf("Invalid duration %s", window.Duration)
+		}
+	case oneOff:
+		var err error
+		if window.start, err = parseTime(window.Start, location); err != nil {
+			return fmt.Errorf("Invalid start %s", window.Start)
+		}
+		if window.end, err = parseTime(window.End, location); err != nil {
+			return fmt.Errorf("Invalid end %s", window.End)
+		}
+		if !window.end.After(window.start) {
+			return errors.New("End has to be after start")
+		}
+	default:
+		return errors.New("Either start and end or cron/rrule and duration are required")
+	}
+	return nil
+}
+
+// Accepts RFC 3339 times and local times without offset in the window's timezone
+func parseTime(value string, location *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05", value, location)
+}
+
+func (window *Window) appliesTo(check Check, registered bool) bool {
+	if len(window.Groups) == 0 && len(window.Checks) == 0 {
+		return true
+	}
+	if !registered {
+		return false
+	}
+	for _, group := range window.Groups {
+		if len(check.Group) != 0 && group == check.Group {
+			return true
+		}
+	}
+	for _, name := range window.Checks {
+		if (len(check.Name) != 0 && name == check.Name) || name == check.Url {
+			return true
+		}
+	}
+	return false
+}
+
+func (window *Window) activeAt(t time.Time) bool {
+	if window.schedule == nil {
+		return !t.Before(window.start) && t.Before(window.end)
+	}
+	// active if the window started within the last duration
+	start := window.schedule.Next(t.Add(-window.duration))
+	return !start.IsZero() && !start.After(t)
+}
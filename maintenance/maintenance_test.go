@@ -0,0 +1,128 @@
+package maintenance
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidWindows(t *testing.T) {
+	invalid := []Window{
+		{},
+		{Start: "2021-09-07T22:00:00Z"},
+		{Start: "2021-09-07T22:00:00Z", End: "2021-09-07T21:00:00Z"},
+		{Cron: "0 22 * * TUE"},
+		{Cron: "0 22 * * TUE", Duration: "2h", Start: "2021-09-07T22:00:00Z"},
+		{Cron: "0 22 * * TUE", RRule: "FREQ=DAILY", Duration: "2h"},
+		{Cron: "0 22 * * TUE", Duration: "2h", Timezone: "Nowhere/Invalid"},
+	}
+
+	for _, window := range invalid {
+		assert.Error(t, window.Validate(), "%+v", window)
+	}
+}
+
+func TestOneOffWindow(t *testing.T) {
+	t.Cleanup(func() { Initialize(nil) })
+
+	err := Initialize([]Window{{Start: "2021-09-07T22:00:00Z", End: "2021-09-07T23:00:00Z"}})
+	assert.Nil(t, err)
+
+	assert.False(t, IsActive(1, time.Date(2021, time.September, 7, 21, 59, 0, 0, time.UTC)))
+	assert.True(t, IsActive(1, time.Date(2021, time.September, 7, 22, 0, 0, 0, time.UTC)))
+	assert.False(t, IsActive(1, time.Date(2021, time.September, 7, 23, 0, 0, 0, time.UTC)))
+}
+
+func TestRecurringWindowForGroup(t *testing.T) {
+	t.Cleanup(func() { Initialize(nil) })
+
+	RegisterCheck(Check{Id: 1, Name: "api", Group: "backend", Url: "http://api.test.com"})
+	RegisterCheck(Check{Id: 2, Name: "website", Group: "frontend", Url: "http://test.com"})
+
+	err := Initialize([]Window{{RRule: "FREQ=WEEKLY;BYDAY=TU;BYHOUR=22", Duration: "2h", Timezone: "Europe/Berlin", Groups: []string{"backend"}}})
+	assert.Nil(t, err)
+
+	location, _ := time.LoadLocation("Europe/Berlin")
+	assert.True(t, IsActive(1, time.Date(2021, time.September, 7, 23, 30, 0, 0, location)))
+	assert.False(t, IsActive(2, time.Date(2021, time.September, 7, 23, 30, 0, 0, location)))
+	assert.False(t, IsActive(1, time.Date(2021, time.September, 8, 0, 0, 0, 0, location)))
+	assert.False(t, IsActive(1, time.Date(2021, time.September, 8, 22, 30, 0, 0, location)))
+}
+
+func TestWindowForCheck(t *testing.T) {
+	t.Cleanup(func() { Initialize(nil) })
+
+	RegisterCheck(Check{Id: 1, Name: "api", Url: "http://api.test.com"})
+	RegisterCheck(Check{Id: 2, Url: "http://test.com"})
+
+	err := Initialize([]Window{{Cron: "@daily", Duration: "24h", Checks: []string{"api", "http://test.com"}}})
+	assert.Nil(t, err)
+
+	assert.True(t, IsActive(1, time.Now()))
+	assert.True(t, IsActive(2, time.Now()))
+	assert.False(t, IsActive(3, time.Now()))
+}
+
+func TestHttpHandler(t *testing.T) {
+	t.Cleanup(func() { Initialize(nil) })
+	Initialize(nil)
+
+	handler := HttpHandler("secret")
+	request := func(method string, target string, body *bytes.Buffer) *http.Request {
+		r := httptest.NewRequest(method, target, nil)
+		if body != nil {
+			r = httptest.NewRequest(method, target, body)
+		}
+		r.Header.Set("Authorization", "Bearer secret")
+		return r
+	}
+
+	body := bytes.NewBufferString(`{"name":"deployment","start":"2000-01-01T00:00:00Z","end":"2100-01-01T00:00:00Z"}`)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request(http.MethodPost, "/maintenance", body))
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"id":1`)
+	assert.True(t, IsActive(1, time.Now()))
+
+	recorder = httptest.NewRecorder()
+	handler(recorder, request(http.MethodGet, "/maintenance", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"name":"deployment"`)
+
+	recorder = httptest.NewRecorder()
+	handler(recorder, request(http.MethodPost, "/maintenance", bytes.NewBufferString(`{"start":"yesterday"}`)))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler(recorder, request(http.MethodDelete, "/maintenance?id=1", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.False(t, IsActive(1, time.Now()))
+
+	recorder = httptest.NewRecorder()
+	handler(recorder, request(http.MethodDelete, "/maintenance?id=1", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// requests without the token cannot change the windows
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/maintenance", bytes.NewBufferString(`{"start":"2000-01-01T00:00:00Z","end":"2100-01-01T00:00:00Z"}`)),
+		httptest.NewRequest(http.MethodGet, "/maintenance", nil),
+	} {
+		recorder = httptest.NewRecorder()
+		handler(recorder, r)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	}
+	r := request(http.MethodGet, "/maintenance", nil)
+	r.Header.Set("Authorization", "Bearer wrong")
+	recorder = httptest.NewRecorder()
+	handler(recorder, r)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	HttpHandler("")(recorder, httptest.NewRequest(http.MethodGet, "/maintenance", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Empty(t, List(time.Now()))
+}
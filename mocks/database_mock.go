@@ -12,6 +12,7 @@ type Database interface {
 	GetDatabaseName() string
 	AddRequestInfo(requestInfo RequestInfo) error
 	AddErrorInfo(errorInfo ErrorInfo) error
+	AddResultInfo(resultInfo ResultInfo) error
 }
 */
 
@@ -63,3 +64,12 @@ func (m *MockedDatabase) AddErrorInfo(errorInfo model.ErrorInfo) error {
 	}
 	return nil
 }
+
+func (m *MockedDatabase) AddResultInfo(resultInfo model.ResultInfo) error {
+	args := m.Called()
+
+	if len(args) > 0 {
+		return args.Get(0).(error)
+	}
+	return nil
+}
@@ -1,11 +1,47 @@
 package model
 
+// Categories of failed requests
+const (
+	CategoryInvalidRequest     = "invalid_request"
+	CategoryDnsFailure         = "dns_failure"
+	CategoryConnectionRefused  = "connection_refused"
+	CategoryConnectionReset    = "connection_reset"
+	CategoryTimeout            = "timeout"
+	CategoryTlsHandshake       = "tls_handshake_error"
+	CategoryCertificateInvalid = "certificate_invalid"
+	CategoryCertificateExpiry  = "certificate_expiring"
+	CategoryUnexpectedStatus   = "unexpected_status"
+	CategoryAssertionFailed    = "assertion_failed"
+	CategoryBodyRead           = "body_read_error"
+	CategoryQueryFailed        = "query_failed"
+	CategoryTokenFetch         = "token_fetch_failed"
+	CategoryAuthFailed         = "auth_failed"
+	CategoryClockSkew          = "clock_skew"
+	CategoryContentChanged     = "content_changed"
+	CategoryContentStale       = "content_stale"
+	CategoryAuditRegression    = "audit_regression"
+	CategoryBrokenLinks        = "broken_links"
+	CategoryProxy              = "proxy_error"
+	CategoryCheckWarning       = "check_warning"
+	CategoryCheckCritical      = "check_critical"
+	CategoryCheckUnknown       = "check_unknown"
+	CategoryRequestFailed      = "request_failed"
+)
+
 type ErrorInfo struct {
-	Id           int
-	Url          string
-	RequestType  string
-	ResponseCode int
-	ResponseBody string
-	Reason       error
-	OtherInfo    string
+	Id             int
+	Url            string
+	RequestType    string
+	ResponseCode   int
+	ResponseTimeMs int64
+	Headers        map[string]string
+	ResponseBody   string
+	Reason         error
+	Category       string
+	OtherInfo      string
+	Step           string // failing step of a transaction check
+	Flapping       bool
+	Maintenance    bool
+	Attempts       int
+	Metrics        map[string]float64 // further measurements of the check e.g. handshakeMs
 }
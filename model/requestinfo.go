@@ -7,4 +7,9 @@ type RequestInfo struct {
 	ResponseCode         int
 	ResponseTimeMs       int64
 	ExpectedResponseTime int64
+	Flapping             bool
+	Maintenance          bool
+	Attempts             int
+	Metrics              map[string]float64 // further measurements of the check e.g. handshakeMs
+	AuditFindings        []string           // findings of the security audit, nil if the check has no audit
 }
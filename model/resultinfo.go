@@ -0,0 +1,16 @@
+package model
+
+// ResultInfo is the outcome of a single attempt to perform a request, successful or not
+type ResultInfo struct {
+	Id             int
+	Url            string
+	RequestType    string
+	Success        bool
+	ResponseCode   int
+	ResponseTimeMs int64
+	Category       string
+	Headers        map[string]string
+	Attempt        int
+	Maintenance    bool
+	Metrics        map[string]float64
+}
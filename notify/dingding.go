@@ -27,7 +27,7 @@ func (dingdingNotify DingdingNotify) GetClientName() string {
 }
 
 func (dingdingNotify DingdingNotify) Initialize() error {
-	return nil
+	return validateTLS(dingdingNotify.TLS)
 }
 
 func (dingdingNotify DingdingNotify) SendResponseTimeNotification(responseTimeNotification ResponseTimeNotification) error {
@@ -57,7 +57,10 @@ func (dingdingNotify DingdingNotify) SendResponseTimeNotification(responseTimeNo
 
 	AddHeaders(request, dingdingNotify.Headers)
 
-	client := &http.Client{}
+	client, clientErr := dingdingNotify.TLS.Client()
+	if clientErr != nil {
+		return clientErr
+	}
 
 	getResponse, respErr := client.Do(request)
 	fmt.Printf("%v", respErr)
@@ -104,7 +107,10 @@ func (dingdingNotify DingdingNotify) SendErrorNotification(errorNotification Err
 
 	AddHeaders(request, dingdingNotify.Headers)
 
-	client := &http.Client{}
+	client, clientErr := dingdingNotify.TLS.Client()
+	if clientErr != nil {
+		return clientErr
+	}
 
 	getResponse, respErr := client.Do(request)
 
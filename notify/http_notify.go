@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"statusok/tlsutil"
 	"strconv"
 )
 
@@ -22,6 +23,7 @@ type HttpNotify struct {
 	Url         string            `json:"url"`
 	RequestType string            `json:"requestType"`
 	Headers     map[string]string `json:"headers"`
+	TLS         *tlsutil.Config   `json:"tls"`
 }
 
 type MessageParam struct {
@@ -33,7 +35,7 @@ func (httpNotify HttpNotify) GetClientName() string {
 }
 
 func (httpNotify HttpNotify) Initialize() error {
-	return nil
+	return validateTLS(httpNotify.TLS)
 }
 
 func (httpNotify HttpNotify) SendResponseTimeNotification(responseTimeNotification ResponseTimeNotification) error {
@@ -74,7 +76,10 @@ func (httpNotify HttpNotify) SendResponseTimeNotification(responseTimeNotificati
 
 	AddHeaders(request, httpNotify.Headers)
 
-	client := &http.Client{}
+	client, clientErr := httpNotify.TLS.Client()
+	if clientErr != nil {
+		return clientErr
+	}
 
 	getResponse, respErr := client.Do(request)
 
@@ -131,7 +136,10 @@ func (httpNotify HttpNotify) SendErrorNotification(errorNotification ErrorNotifi
 
 	AddHeaders(request, httpNotify.Headers)
 
-	client := &http.Client{}
+	client, clientErr := httpNotify.TLS.Client()
+	if clientErr != nil {
+		return clientErr
+	}
 
 	getResponse, respErr := client.Do(request)
 
@@ -149,6 +157,14 @@ func (httpNotify HttpNotify) SendErrorNotification(errorNotification ErrorNotifi
 	return nil
 }
 
+// Validates the tls settings of a notification client if given
+func validateTLS(config *tlsutil.Config) error {
+	if config == nil {
+		return nil
+	}
+	return config.Validate()
+}
+
 func AddHeaders(req *http.Request, headers map[string]string) {
 	for key, value := range headers {
 		req.Header.Add(key, value)
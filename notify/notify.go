@@ -5,7 +5,7 @@ import (
 	"os"
 	"reflect"
 	"regexp"
-	"strings"
+	"statusok/tlsutil"
 )
 
 // Diffrent types of clients to deliver notifications
@@ -31,6 +31,7 @@ type ErrorNotification struct {
 	ResponseBody string
 	Error        string
 	OtherInfo    string
+	Category     string
 }
 
 var (
@@ -45,14 +46,29 @@ type Notify interface {
 	SendErrorNotification(notification ErrorNotification) error
 }
 
+// Configured clients sending over https use the default tls settings for the ones they do not give
+func (notificationTypes *NotificationTypes) SetTLSDefaults(defaults *tlsutil.Config) {
+	if !reflect.ValueOf(notificationTypes.Slack).IsZero() {
+		notificationTypes.Slack.TLS = notificationTypes.Slack.TLS.WithDefaults(defaults)
+	}
+	if !reflect.ValueOf(notificationTypes.Http).IsZero() {
+		notificationTypes.Http.TLS = notificationTypes.Http.TLS.WithDefaults(defaults)
+	}
+	if !reflect.ValueOf(notificationTypes.Dingding).IsZero() {
+		notificationTypes.Dingding.TLS = notificationTypes.Dingding.TLS.WithDefaults(defaults)
+	}
+	if !reflect.ValueOf(notificationTypes.Pagerduty).IsZero() {
+		notificationTypes.Pagerduty.TLS = notificationTypes.Pagerduty.TLS.WithDefaults(defaults)
+	}
+}
+
 // Add notification clients given by user in config file to notificationsList
 func AddNew(notificationTypes NotificationTypes) {
 	v := reflect.ValueOf(notificationTypes)
 
 	for i := 0; i < v.NumField(); i++ {
-		notifyString := fmt.Sprint(v.Field(i).Interface().(Notify))
 		// Check whether notify object is empty . if its not empty add to the list
-		if !isEmptyObject(notifyString) {
+		if !v.Field(i).IsZero() {
 			notificationsList = append(notificationsList, v.Field(i).Interface().(Notify))
 		}
 	}
@@ -76,6 +92,11 @@ func AddNew(notificationTypes NotificationTypes) {
 	}
 }
 
+// Remove all registered notification clients
+func ResetNotifications() {
+	notificationsList = []Notify{}
+}
+
 // Send response time notification to all clients registered
 func SendResponseTimeNotification(responseTimeNotification ResponseTimeNotification) {
 	for _, value := range notificationsList {
@@ -111,7 +132,7 @@ func SendTestNotification() {
 			println("Sent Test Response Time notification to ", value.GetClientName(), ". Make sure you received it")
 		}
 
-		err1 := value.SendErrorNotification(ErrorNotification{"http://test.com", "GET", "This is test notification", "Test notification", "test"})
+		err1 := value.SendErrorNotification(ErrorNotification{"http://test.com", "GET", "This is test notification", "Test notification", "test", "test"})
 
 		if err1 != nil {
 			println("Failed to Send Error notification to ", value.GetClientName(), " Please check the details entered in the config file")
@@ -128,21 +149,6 @@ func validateEmail(email string) bool {
 	return Re.MatchString(email)
 }
 
-func isEmptyObject(objectString string) bool {
-	objectString = strings.Replace(objectString, "0", "", -1)
-	objectString = strings.Replace(objectString, "map", "", -1)
-	objectString = strings.Replace(objectString, "[]", "", -1)
-	objectString = strings.Replace(objectString, " ", "", -1)
-	objectString = strings.Replace(objectString, "{", "", -1)
-	objectString = strings.Replace(objectString, "}", "", -1)
-
-	if len(objectString) > 0 {
-		return false
-	} else {
-		return true
-	}
-}
-
 // A readable message string from responseTimeNotification
 func getMessageFromResponseTimeNotification(responseTimeNotification ResponseTimeNotification) string {
 	message := fmt.Sprintf("Notification From StatusOk\n\nOne of your apis response time is below than expected."+
@@ -157,8 +163,8 @@ func getMessageFromResponseTimeNotification(responseTimeNotification ResponseTim
 func getMessageFromErrorNotification(errorNotification ErrorNotification) string {
 	message := fmt.Sprintf("Notification From StatusOk\n\nWe are getting error when we try to send request to one of your apis"+
 		"\n\nPlease find the Details below"+
-		"\n\nUrl: %v \nRequestType: %v \nError Message: %v \nResponse Body: %v\nOther Info:%v\n"+
-		"\n\nThanks", errorNotification.Url, errorNotification.RequestType, errorNotification.Error, errorNotification.ResponseBody, errorNotification.OtherInfo)
+		"\n\nUrl: %v \nRequestType: %v \nFailure Type: %v \nError Message: %v \nResponse Body: %v\nOther Info:%v\n"+
+		"\n\nThanks", errorNotification.Url, errorNotification.RequestType, errorNotification.Category, errorNotification.Error, errorNotification.ResponseBody, errorNotification.OtherInfo)
 
 	return message
 }
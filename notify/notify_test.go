@@ -1,6 +1,7 @@
 package notify
 
 import (
+	"statusok/tlsutil"
 	"testing"
 )
 
@@ -26,7 +27,7 @@ func TestAddValidNotifyObject(t *testing.T) {
 		MailNotify{},
 		MailgunNotify{},
 		SlackNotify{},
-		HttpNotify{"http://statusOk.com", "GET", nil},
+		HttpNotify{Url: "http://statusOk.com", RequestType: "GET"},
 		DingdingNotify{},
 		PagerdutyNotify{},
 	}
@@ -37,3 +38,23 @@ func TestAddValidNotifyObject(t *testing.T) {
 		t.Error("Failed to Add Notification Object to list")
 	}
 }
+
+func TestSetTLSDefaults(t *testing.T) {
+	defaults := &tlsutil.Config{CaFile: "ca.pem", MinVersion: "1.2"}
+	notificationTypes := NotificationTypes{
+		Slack: SlackNotify{ChannelWebhookURL: "https://hooks.slack.com/services/test"},
+		Http:  HttpNotify{Url: "https://statusOk.com", RequestType: "POST", TLS: &tlsutil.Config{MinVersion: "1.3"}},
+	}
+
+	notificationTypes.SetTLSDefaults(defaults)
+
+	if notificationTypes.Slack.TLS == nil || notificationTypes.Slack.TLS.CaFile != "ca.pem" {
+		t.Error("Default tls settings should be used by a client without tls settings")
+	}
+	if tls := notificationTypes.Http.TLS; tls.CaFile != "ca.pem" || tls.MinVersion != "1.3" {
+		t.Error("Default tls settings should only be used for the settings a client does not give")
+	}
+	if notificationTypes.Pagerduty.TLS != nil || notificationTypes.Dingding.TLS != nil {
+		t.Error("Clients which are not configured should stay empty")
+	}
+}
@@ -7,13 +7,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"statusok/tlsutil"
 	"time"
 )
 
 type PagerdutyNotify struct {
-	Url        string `json:"url"`
-	RoutingKey string `json:"routingKey"`
-	Severity   string `json:"severity"`
+	Url        string          `json:"url"`
+	RoutingKey string          `json:"routingKey"`
+	Severity   string          `json:"severity"`
+	TLS        *tlsutil.Config `json:"tls"`
 }
 
 type RequestBody struct {
@@ -35,7 +37,7 @@ func (pagerdutyNotify PagerdutyNotify) GetClientName() string {
 }
 
 func (pagerdutyNotify PagerdutyNotify) Initialize() error {
-	return nil
+	return validateTLS(pagerdutyNotify.TLS)
 }
 
 func (pagerdutyNotify PagerdutyNotify) SendResponseTimeNotification(responseTimeNotification ResponseTimeNotification) error {
@@ -56,7 +58,10 @@ func (pagerdutyNotify PagerdutyNotify) SendResponseTimeNotification(responseTime
 		return reqErr
 	}
 
-	client := &http.Client{}
+	client, clientErr := pagerdutyNotify.TLS.Client()
+	if clientErr != nil {
+		return clientErr
+	}
 
 	getResponse, respErr := client.Do(request)
 
@@ -93,7 +98,10 @@ func (pagerdutyNotify PagerdutyNotify) SendErrorNotification(errorNotification E
 		return reqErr
 	}
 
-	client := &http.Client{}
+	client, clientErr := pagerdutyNotify.TLS.Client()
+	if clientErr != nil {
+		return clientErr
+	}
 
 	getResponse, respErr := client.Do(request)
 
@@ -6,15 +6,17 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"statusok/tlsutil"
 	"strconv"
 	"strings"
 )
 
 type SlackNotify struct {
-	Username          string `json:"username"`
-	ChannelName       string `json:"channelName"` //Not mandatory field
-	ChannelWebhookURL string `json:"channelWebhookURL"`
-	IconUrl           string `json:"iconUrl"`
+	Username          string          `json:"username"`
+	ChannelName       string          `json:"channelName"` //Not mandatory field
+	ChannelWebhookURL string          `json:"channelWebhookURL"`
+	IconUrl           string          `json:"iconUrl"`
+	TLS               *tlsutil.Config `json:"tls"`
 }
 
 type postMessage struct {
@@ -38,7 +40,7 @@ func (slackNotify SlackNotify) Initialize() error {
 		return errors.New("Slack: channelWebhookURL is a required field")
 	}
 
-	return nil
+	return validateTLS(slackNotify.TLS)
 }
 
 func (slackNotify SlackNotify) SendResponseTimeNotification(responseTimeNotification ResponseTimeNotification) error {
@@ -51,7 +53,12 @@ func (slackNotify SlackNotify) SendResponseTimeNotification(responseTimeNotifica
 		return jsonErr
 	}
 
-	getResponse, respErr := http.Post(slackNotify.ChannelWebhookURL, "application/json", payload)
+	client, clientErr := slackNotify.TLS.Client()
+	if clientErr != nil {
+		return clientErr
+	}
+
+	getResponse, respErr := client.Post(slackNotify.ChannelWebhookURL, "application/json", payload)
 
 	if respErr != nil {
 		return respErr
@@ -76,7 +83,12 @@ func (slackNotify SlackNotify) SendErrorNotification(errorNotification ErrorNoti
 		return jsonErr
 	}
 
-	getResponse, respErr := http.Post(slackNotify.ChannelWebhookURL, "application/json", payload)
+	client, clientErr := slackNotify.TLS.Client()
+	if clientErr != nil {
+		return clientErr
+	}
+
+	getResponse, respErr := client.Post(slackNotify.ChannelWebhookURL, "application/json", payload)
 
 	if respErr != nil {
 		return respErr
@@ -0,0 +1,83 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// HeaderAssertion checks a response header. Exactly one of Exists, Equals, Matches
+// (a regular expression) or Absent has to be given.
+type HeaderAssertion struct {
+	Header  string `json:"header"`
+	Exists  bool   `json:"exists"`
+	Equals  string `json:"equals"`
+	Matches string `json:"matches"`
+	Absent  bool   `json:"absent"`
+}
+
+// check whether the assertion is valid
+func (assertion HeaderAssertion) Validate() error {
+	if len(assertion.Header) == 0 {
+		return errors.New("Header of header assertion cannot be empty")
+	}
+
+	count := 0
+	for _, given := range []bool{assertion.Exists, len(assertion.Equals) != 0, len(assertion.Matches) != 0, assertion.Absent} {
+		if given {
+			count++
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("Header assertion for %s needs exactly one of exists, equals, matches or absent", assertion.Header)
+	}
+
+	if len(assertion.Matches) != 0 {
+		if _, err := regexp.Compile(assertion.Matches); err != nil {
+			return fmt.Errorf("Header assertion for %s has an invalid regular expression: %s", assertion.Header, err)
+		}
+	}
+	return nil
+}
+
+// Returns an error describing why the header does not fulfill the assertion
+func (assertion HeaderAssertion) Check(header http.Header) error {
+	values, present := header[http.CanonicalHeaderKey(assertion.Header)]
+	value := header.Get(assertion.Header)
+
+	switch {
+	case assertion.Absent:
+		if present {
+			return fmt.Errorf("Header %s is present with value \"%s\", expected it to be absent", assertion.Header, value)
+		}
+	case !present:
+		return fmt.Errorf("Header %s is missing", assertion.Header)
+	case len(assertion.Equals) != 0:
+		if value != assertion.Equals {
+			return fmt.Errorf("Header %s is \"%s\", expected \"%s\"", assertion.Header, value, assertion.Equals)
+		}
+	case len(assertion.Matches) != 0:
+		re, err := regexp.Compile(assertion.Matches)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			if re.MatchString(v) {
+				return nil
+			}
+		}
+		return fmt.Errorf("Header %s is \"%s\", expected it to match \"%s\"", assertion.Header, value, assertion.Matches)
+	}
+	return nil
+}
+
+// Check all assertions, returns the error of the first one failing
+func checkHeaderAssertions(assertions []HeaderAssertion, header http.Header) error {
+	for _, assertion := range assertions {
+		if err := assertion.Check(header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package requests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"statusok/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateHeaderAssertion(t *testing.T) {
+	assert.Nil(t, HeaderAssertion{Header: "Cache-Control", Exists: true}.Validate())
+	assert.Nil(t, HeaderAssertion{Header: "X-Version", Matches: `^2\.`}.Validate())
+
+	assert.Error(t, HeaderAssertion{Exists: true}.Validate())
+	assert.Error(t, HeaderAssertion{Header: "Cache-Control"}.Validate())
+	assert.Error(t, HeaderAssertion{Header: "Cache-Control", Exists: true, Absent: true}.Validate())
+	assert.Error(t, HeaderAssertion{Header: "X-Version", Matches: `(`}.Validate())
+}
+
+func TestCheckHeaderAssertion(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Strict-Transport-Security", "max-age=31536000")
+
+	passing := []HeaderAssertion{
+		{Header: "strict-transport-security", Exists: true},
+		{Header: "Content-Type", Equals: "application/json; charset=utf-8"},
+		{Header: "Content-Type", Matches: "^application/json"},
+		{Header: "Server", Absent: true},
+	}
+	assert.Nil(t, checkHeaderAssertions(passing, header))
+
+	assert.EqualError(t, HeaderAssertion{Header: "Cache-Control", Exists: true}.Check(header), "Header Cache-Control is missing")
+	assert.EqualError(t, HeaderAssertion{Header: "Content-Type", Equals: "text/html"}.Check(header), `Header Content-Type is "application/json; charset=utf-8", expected "text/html"`)
+	assert.EqualError(t, HeaderAssertion{Header: "Content-Type", Matches: "^text/"}.Check(header), `Header Content-Type is "application/json; charset=utf-8", expected it to match "^text/"`)
+	assert.EqualError(t, HeaderAssertion{Header: "Strict-Transport-Security", Absent: true}.Check(header), `Header Strict-Transport-Security is present with value "max-age=31536000", expected it to be absent`)
+}
+
+func TestHeaderAssertionFailsRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+	}))
+	defer server.Close()
+
+	requestConfig := RequestConfig{Id: 1, Url: server.URL, RequestType: "GET", ResponseTime: 100, HeaderAssertions: []HeaderAssertion{{Header: "Cache-Control", Equals: "public"}}}
+	result := performHttpRequest(requestConfig)
+
+	assert.NotNil(t, result.errorInfo)
+	assert.Equal(t, model.CategoryAssertionFailed, result.errorInfo.Category)
+	assert.EqualError(t, result.err, `Header Cache-Control is "no-store", expected "public"`)
+}
@@ -0,0 +1,206 @@
+package requests
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"statusok/tlsutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	AuditHsts         = "hsts"
+	AuditCsp          = "csp"
+	AuditFrameOptions = "frameOptions"
+	AuditCookies      = "cookies"
+	AuditTls          = "tls"
+	AuditRedirect     = "redirect"
+
+	DefaultAuditMinTlsVersion = "1.2"
+	DefaultAuditHstsMinAge    = 180 * 24 * 60 * 60
+)
+
+// AuditConfig grades the security posture of the response of a http check: security headers,
+// cookie flags, the negotiated TLS version and cipher and the redirect from http to https.
+// Audits named in Skip are not performed.
+type AuditConfig struct {
+	Skip          []string `json:"skip"`
+	MinTlsVersion string   `json:"minTlsVersion"`
+	HstsMinAge    int64    `json:"hstsMinAge"`
+	RedirectFrom  string   `json:"redirectFrom"`
+}
+
+// check the audits to skip and the thresholds
+func (audit *AuditConfig) Validate() error {
+	for _, name := range audit.Skip {
+		switch name {
+		case AuditHsts, AuditCsp, AuditFrameOptions, AuditCookies, AuditTls, AuditRedirect:
+		default:
+			return fmt.Errorf("Unknown audit %s to skip", name)
+		}
+	}
+	if len(audit.MinTlsVersion) == 0 {
+		audit.MinTlsVersion = DefaultAuditMinTlsVersion
+	}
+	if _, ok := tlsutil.Versions[audit.MinTlsVersion]; !ok {
+		return fmt.Errorf("Invalid minTlsVersion %s, use 1.0, 1.1, 1.2 or 1.3", audit.MinTlsVersion)
+	}
+	if audit.HstsMinAge < 0 {
+		return errors.New("HstsMinAge cannot be negative")
+	}
+	if audit.HstsMinAge == 0 {
+		audit.HstsMinAge = DefaultAuditHstsMinAge
+	}
+	if len(audit.RedirectFrom) != 0 {
+		if from, err := url.Parse(audit.RedirectFrom); err != nil || from.Scheme != "http" {
+			return fmt.Errorf("Invalid redirectFrom %s, has to be a http url", audit.RedirectFrom)
+		}
+	}
+	return nil
+}
+
+func (audit *AuditConfig) enabled(name string) bool {
+	for _, skipped := range audit.Skip {
+		if skipped == name {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the max-age of a Strict-Transport-Security header, -1 if it has none
+func hstsMaxAge(header string) int64 {
+	for _, directive := range strings.Split(header, ";") {
+		parts := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "max-age") {
+			if maxAge, err := strconv.ParseInt(strings.Trim(parts[1], `"`), 10, 64); err == nil {
+				return maxAge
+			}
+		}
+	}
+	return -1
+}
+
+// Returns the findings of the audit of the response, each prefixed with the name of its audit
+func (audit *AuditConfig) findings(response *http.Response, client *http.Client) []string {
+	findings := make([]string, 0)
+	add := func(name string, format string, args ...interface{}) {
+		findings = append(findings, name+": "+fmt.Sprintf(format, args...))
+	}
+	secure := response.Request.URL.Scheme == "https"
+
+	if audit.enabled(AuditTls) {
+		if response.TLS == nil {
+			add(AuditTls, "%s is not served over https", response.Request.URL)
+		} else {
+			if response.TLS.Version < tlsutil.Versions[audit.MinTlsVersion] {
+				add(AuditTls, "Protocol %s is below TLS %s", tlsVersionName(response.TLS.Version), audit.MinTlsVersion)
+			}
+			cipher := tls.CipherSuiteName(response.TLS.CipherSuite)
+			for _, insecure := range tls.InsecureCipherSuites() {
+				if insecure.ID == response.TLS.CipherSuite {
+					add(AuditTls, "Cipher %s is insecure", cipher)
+				}
+			}
+			if strings.HasPrefix(cipher, "TLS_RSA_") {
+				add(AuditTls, "Cipher %s has no forward secrecy", cipher)
+			}
+		}
+	}
+
+	if audit.enabled(AuditHsts) && secure {
+		header := response.Header.Get("Strict-Transport-Security")
+		if maxAge := hstsMaxAge(header); len(header) == 0 {
+			add(AuditHsts, "Strict-Transport-Security header is missing")
+		} else if maxAge < audit.HstsMinAge {
+			add(AuditHsts, "Strict-Transport-Security max-age %d is below %d", maxAge, audit.HstsMinAge)
+		}
+	}
+
+	csp := response.Header.Get("Content-Security-Policy")
+	if audit.enabled(AuditCsp) && len(csp) == 0 {
+		add(AuditCsp, "Content-Security-Policy header is missing")
+	}
+
+	// frame-ancestors of the policy replaces X-Frame-Options
+	if audit.enabled(AuditFrameOptions) && !strings.Contains(strings.ToLower(csp), "frame-ancestors") {
+		frameOptions := response.Header.Get("X-Frame-Options")
+		if len(frameOptions) == 0 {
+			add(AuditFrameOptions, "X-Frame-Options header is missing")
+		} else if !strings.EqualFold(frameOptions, "DENY") && !strings.EqualFold(frameOptions, "SAMEORIGIN") {
+			add(AuditFrameOptions, "X-Frame-Options %q is not DENY or SAMEORIGIN", frameOptions)
+		}
+	}
+
+	if audit.enabled(AuditCookies) {
+		for _, cookie := range response.Cookies() {
+			var missing []string
+			if secure && !cookie.Secure {
+				missing = append(missing, "Secure")
+			}
+			if !cookie.HttpOnly {
+				missing = append(missing, "HttpOnly")
+			}
+			// a missing or empty attribute leaves the default of the browser
+			if cookie.SameSite == 0 || cookie.SameSite == http.SameSiteDefaultMode {
+				missing = append(missing, "SameSite")
+			}
+			if len(missing) != 0 {
+				add(AuditCookies, "Cookie %s lacks %s", cookie.Name, strings.Join(missing, ", "))
+			}
+		}
+	}
+
+	if audit.enabled(AuditRedirect) && secure {
+		if finding := audit.checkRedirect(response.Request.URL, client); len(finding) != 0 {
+			add(AuditRedirect, "%s", finding)
+		}
+	}
+	return findings
+}
+
+// Requests the http url of the check and returns a finding unless it redirects to https
+func (audit *AuditConfig) checkRedirect(target *url.URL, client *http.Client) string {
+	from := audit.RedirectFrom
+	if len(from) == 0 {
+		plain := *target
+		plain.Scheme, plain.Host = "http", target.Hostname()
+		if strings.Contains(plain.Host, ":") {
+			plain.Host = "[" + plain.Host + "]"
+		}
+		from = plain.String()
+	}
+
+	// only the first response counts
+	noRedirects := *client
+	noRedirects.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, err := noRedirects.Get(from)
+	if err != nil {
+		// findings are compared with the previous audit, so leave out the error text which varies e.g. with the local port
+		return fmt.Sprintf("Requesting %s failed with %s", from, classifyError(err))
+	}
+	response.Body.Close()
+
+	location, err := response.Location()
+	switch {
+	case response.StatusCode < 300 || response.StatusCode >= 400:
+		return fmt.Sprintf("%s answers with %d instead of redirecting to https", from, response.StatusCode)
+	case err != nil || location.Scheme != "https":
+		return fmt.Sprintf("%s redirects to %s instead of https", from, response.Header.Get("Location"))
+	}
+	return ""
+}
+
+func tlsVersionName(version uint16) string {
+	for name, v := range tlsutil.Versions {
+		if v == version {
+			return "TLS " + name
+		}
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
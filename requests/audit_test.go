@@ -0,0 +1,112 @@
+package requests
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"statusok/model"
+	"statusok/tlsutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHstsMaxAge(t *testing.T) {
+	assert.Equal(t, int64(31536000), hstsMaxAge("max-age=31536000; includeSubDomains"))
+	assert.Equal(t, int64(600), hstsMaxAge(`includeSubDomains; Max-Age="600"`))
+	assert.Equal(t, int64(-1), hstsMaxAge("includeSubDomains"))
+}
+
+func TestValidateAudit(t *testing.T) {
+	audit := &AuditConfig{}
+	assert.Nil(t, audit.Validate())
+	assert.Equal(t, DefaultAuditMinTlsVersion, audit.MinTlsVersion)
+	assert.Equal(t, int64(DefaultAuditHstsMinAge), audit.HstsMinAge)
+
+	for _, invalid := range []*AuditConfig{
+		{Skip: []string{"xss"}},
+		{MinTlsVersion: "1.4"},
+		{HstsMinAge: -1},
+		{RedirectFrom: "https://mywebsite.com"},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+
+	requestConfig := RequestConfig{Type: TypeSteps, Audit: &AuditConfig{}, ResponseTime: 100}
+	assert.Error(t, requestConfig.Validate())
+}
+
+func TestAudit(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/secure" {
+			w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+			w.Header().Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1", Secure: true, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+			return
+		}
+		w.Header().Set("Strict-Transport-Security", "max-age=300")
+		w.Header().Set("X-Frame-Options", "ALLOW-FROM https://ads.example.com")
+		http.SetCookie(w, &http.Cookie{Name: "tracking", Value: "1"})
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "https://mywebsite.com/", http.StatusMovedPermanently)
+		}
+	}))
+	defer redirect.Close()
+
+	dir, err := ioutil.TempDir("", "audit")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.Nil(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	requestConfig := RequestConfig{Id: 1, Url: server.URL + "/secure", RequestType: http.MethodGet, TLS: &tlsutil.Config{CaFile: caFile}, ResponseTime: 100,
+		Audit: &AuditConfig{RedirectFrom: redirect.URL}}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Equal(t, []string{}, result.requestInfo.AuditFindings)
+	assert.Equal(t, 0.0, result.requestInfo.Metrics["auditFindings"])
+
+	requestConfig.Url = server.URL + "/weak"
+	requestConfig.Audit = &AuditConfig{MinTlsVersion: "1.3", RedirectFrom: redirect.URL + "/plain"}
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Equal(t, []string{
+		"tls: Protocol TLS 1.2 is below TLS 1.3",
+		"hsts: Strict-Transport-Security max-age 300 is below 15552000",
+		"csp: Content-Security-Policy header is missing",
+		`frameOptions: X-Frame-Options "ALLOW-FROM https://ads.example.com" is not DENY or SAMEORIGIN`,
+		"cookies: Cookie tracking lacks Secure, HttpOnly, SameSite",
+		"redirect: " + redirect.URL + "/plain answers with 200 instead of redirecting to https",
+	}, result.requestInfo.AuditFindings)
+	assert.Equal(t, 6.0, result.requestInfo.Metrics["auditFindings"])
+
+	requestConfig.Audit.Skip = []string{AuditTls, AuditHsts, AuditCsp, AuditFrameOptions, AuditRedirect}
+	result = performAttempt(requestConfig)
+	assert.Equal(t, []string{"cookies: Cookie tracking lacks Secure, HttpOnly, SameSite"}, result.requestInfo.AuditFindings)
+
+	// the finding of a failed request does not vary with the error, e.g. the local port
+	closed := httptest.NewServer(nil)
+	closed.Close()
+	requestConfig.Audit = &AuditConfig{Skip: []string{AuditTls, AuditHsts, AuditCsp, AuditFrameOptions, AuditCookies}, RedirectFrom: closed.URL}
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.Equal(t, []string{"redirect: Requesting " + closed.URL + " failed with " + model.CategoryConnectionRefused}, result.requestInfo.AuditFindings)
+
+	// plain http has no hsts or redirect to audit
+	requestConfig = RequestConfig{Id: 1, Url: redirect.URL + "/plain", RequestType: http.MethodGet, ResponseTime: 100, Audit: &AuditConfig{Skip: []string{AuditCsp, AuditFrameOptions}}}
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.Equal(t, []string{"tls: " + redirect.URL + "/plain is not served over https"}, result.requestInfo.AuditFindings)
+}
@@ -0,0 +1,294 @@
+package requests
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuthBasic  = "basic"
+	AuthDigest = "digest"
+	AuthBearer = "bearer"
+	AuthOAuth2 = "oauth2"
+
+	Authorization        = "Authorization"
+	DefaultRefreshBefore = "60s"
+)
+
+// AuthConfig authenticates the requests of a check.
+// Tokens fetched from an OAuth2 token endpoint with client credentials are cached
+// and fetched again refreshBefore their expiry.
+type AuthConfig struct {
+	Type          string        `json:"type"`
+	Username      string        `json:"username"`
+	Password      string        `json:"password"`
+	BearerFile    string        `json:"bearerFile"`
+	TokenUrl      string        `json:"tokenUrl"`
+	ClientId      string        `json:"clientId"`
+	ClientSecret  string        `json:"clientSecret"`
+	Scopes        []string      `json:"scopes"`
+	RefreshBefore string        `json:"refreshBefore"`
+	_refresh      time.Duration `json:"-"`
+
+	mutex   sync.Mutex
+	_token  string
+	_expiry time.Time
+}
+
+// response of an OAuth2 token endpoint
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// check whether all auth fields are valid
+func (auth *AuthConfig) Validate() error {
+	switch auth.Type {
+	case AuthBasic, AuthDigest:
+		if len(auth.Username) == 0 {
+			return fmt.Errorf("Username cannot be empty for %s auth", auth.Type)
+		}
+	case AuthBearer:
+		if len(auth.BearerFile) == 0 {
+			return errors.New("BearerFile cannot be empty for bearer auth")
+		}
+		if _, err := os.Stat(auth.BearerFile); err != nil {
+			return fmt.Errorf("BearerFile cannot be read: %s", err)
+		}
+	case AuthOAuth2:
+		if len(auth.TokenUrl) == 0 || len(auth.ClientId) == 0 {
+			return errors.New("TokenUrl and ClientId cannot be empty for oauth2 auth")
+		}
+		if _, err := url.Parse(auth.TokenUrl); err != nil {
+			return errors.New("Invalid TokenUrl")
+		}
+		if len(auth.RefreshBefore) == 0 {
+			auth.RefreshBefore = DefaultRefreshBefore
+		}
+		var err error
+		if auth._refresh, err = time.ParseDuration(auth.RefreshBefore); err != nil {
+			return fmt.Errorf("RefreshBefore format is invalid %s", err)
+		}
+	default:
+		return fmt.Errorf("Unknown auth type %s, use %s, %s, %s or %s", auth.Type, AuthBasic, AuthDigest, AuthBearer, AuthOAuth2)
+	}
+	return nil
+}
+
+// Adds credentials to the request. Digest auth is answered after the challenge in do.
+// Errors are failures to get a token.
+func (auth *AuthConfig) apply(request *http.Request, client *http.Client) error {
+	switch auth.Type {
+	case AuthBasic:
+		request.SetBasicAuth(auth.Username, auth.Password)
+	case AuthBearer:
+		data, err := ioutil.ReadFile(auth.BearerFile)
+		if err != nil {
+			return err
+		}
+		request.Header.Set(Authorization, "Bearer "+strings.TrimSpace(string(data)))
+	case AuthOAuth2:
+		token, err := auth.token(client)
+		if err != nil {
+			return err
+		}
+		request.Header.Set(Authorization, "Bearer "+token)
+	}
+	return nil
+}
+
+// Performs the request, answering a digest challenge by sending the request again.
+// A rejected OAuth2 token is dropped so the next request fetches a new one.
+func (auth *AuthConfig) do(client *http.Client, request *http.Request) (*http.Response, error) {
+	response, err := client.Do(request)
+	if auth == nil || err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+
+	switch auth.Type {
+	case AuthOAuth2:
+		auth.invalidate()
+	case AuthDigest:
+		challenge := response.Header.Get("WWW-Authenticate")
+		if !strings.HasPrefix(strings.ToLower(challenge), "digest ") || request.GetBody == nil && request.Body != nil {
+			return response, nil
+		}
+		authorization, err := auth.digestAuthorization(challenge, request)
+		if err != nil {
+			return response, nil
+		}
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+
+		retry := request.Clone(request.Context())
+		if request.GetBody != nil {
+			if retry.Body, err = request.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		retry.Header.Set(Authorization, authorization)
+		return client.Do(retry)
+	}
+	return response, nil
+}
+
+// Returns the cached token or fetches a new one shortly before it expires
+func (auth *AuthConfig) token(client *http.Client) (string, error) {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+
+	if len(auth._token) != 0 && (auth._expiry.IsZero() || time.Now().Add(auth._refresh).Before(auth._expiry)) {
+		return auth._token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(auth.Scopes) != 0 {
+		form.Set("scope", strings.Join(auth.Scopes, " "))
+	}
+	request, err := http.NewRequest(http.MethodPost, auth.TokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set(ContentType, FormContentType)
+	request.SetBasicAuth(url.QueryEscape(auth.ClientId), url.QueryEscape(auth.ClientSecret))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Token endpoint returned response code %d", response.StatusCode)
+	}
+	var tokenResp tokenResponse
+	if err := json.NewDecoder(response.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("Token response is invalid: %s", err)
+	}
+	if len(tokenResp.AccessToken) == 0 {
+		return "", errors.New("Token response has no access_token")
+	}
+
+	auth._token = tokenResp.AccessToken
+	auth._expiry = time.Time{}
+	if tokenResp.ExpiresIn > 0 {
+		auth._expiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+	return auth._token, nil
+}
+
+func (auth *AuthConfig) invalidate() {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	auth._token = ""
+}
+
+// Creates the Authorization header answering a digest challenge (RFC 7616)
+func (auth *AuthConfig) digestAuthorization(challenge string, request *http.Request) (string, error) {
+	params := parseDigestChallenge(challenge[len("digest "):])
+
+	var newHash func() hash.Hash
+	algorithm := params["algorithm"]
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("Unsupported digest algorithm %s", algorithm)
+	}
+	h := func(s string) string {
+		digest := newHash()
+		digest.Write([]byte(s))
+		return hex.EncodeToString(digest.Sum(nil))
+	}
+
+	cnonceBytes := make([]byte, 8)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	nc := "00000001"
+	uri := request.URL.RequestURI()
+
+	ha1 := h(auth.Username + ":" + params["realm"] + ":" + auth.Password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + params["nonce"] + ":" + cnonce)
+	}
+	ha2 := h(request.Method + ":" + uri)
+
+	qop := ""
+	for _, option := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(option) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + params["nonce"] + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + params["nonce"] + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		auth.Username, params["realm"], params["nonce"], uri, response)
+	if len(algorithm) != 0 {
+		authorization += ", algorithm=" + algorithm
+	}
+	if len(params["opaque"]) != 0 {
+		authorization += fmt.Sprintf(`, opaque="%s"`, params["opaque"])
+	}
+	if qop != "" {
+		authorization += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cnonce)
+	}
+	return authorization, nil
+}
+
+// Parses the comma separated key="value" pairs of a digest challenge
+func parseDigestChallenge(challenge string) map[string]string {
+	params := make(map[string]string)
+	for len(challenge) != 0 {
+		challenge = strings.TrimLeft(challenge, " ,")
+		eq := strings.Index(challenge, "=")
+		if eq == -1 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(challenge[:eq]))
+		challenge = strings.TrimSpace(challenge[eq+1:])
+
+		var value string
+		if strings.HasPrefix(challenge, `"`) {
+			end := strings.Index(challenge[1:], `"`)
+			if end == -1 {
+				value, challenge = challenge[1:], ""
+			} else {
+				value, challenge = challenge[1:end+1], challenge[end+2:]
+			}
+		} else {
+			end := strings.Index(challenge, ",")
+			if end == -1 {
+				end = len(challenge)
+			}
+			value, challenge = strings.TrimSpace(challenge[:end]), challenge[end:]
+		}
+		params[key] = value
+	}
+	return params
+}
@@ -0,0 +1,153 @@
+package requests
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"statusok/model"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAuth(t *testing.T) {
+	valid := []AuthConfig{
+		{Type: AuthBasic, Username: "monitor"},
+		{Type: AuthDigest, Username: "monitor"},
+		{Type: AuthOAuth2, TokenUrl: "http://test.com/token", ClientId: "statusok"},
+	}
+	for i := range valid {
+		assert.Nil(t, valid[i].Validate())
+	}
+
+	invalid := []AuthConfig{
+		{Type: "ntlm"},
+		{Type: AuthBasic},
+		{Type: AuthBearer, BearerFile: "/missing/token"},
+		{Type: AuthOAuth2, TokenUrl: "http://test.com/token"},
+		{Type: AuthOAuth2, TokenUrl: "http://test.com/token", ClientId: "statusok", RefreshBefore: "soon"},
+	}
+	for i := range invalid {
+		assert.Error(t, invalid[i].Validate())
+	}
+
+	both := RequestConfig{Url: "http://test.com", RequestType: "GET", ResponseTime: 100, Headers: map[string]string{Authorization: "Bearer abc"}, Auth: &AuthConfig{Type: AuthBasic, Username: "monitor"}}
+	assert.Error(t, both.Validate())
+}
+
+func TestBasicAndBearerAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "token")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("abc\n"), 0600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if r.Header.Get(Authorization) != "Bearer abc" && !(ok && username == "monitor" && password == "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	basic := RequestConfig{Id: 1, Url: server.URL, RequestType: "GET", ResponseTime: 100, Auth: &AuthConfig{Type: AuthBasic, Username: "monitor", Password: "secret"}}
+	assert.Nil(t, performHttpRequest(basic).err)
+
+	bearer := RequestConfig{Id: 1, Url: server.URL, RequestType: "GET", ResponseTime: 100, Auth: &AuthConfig{Type: AuthBearer, BearerFile: file}}
+	assert.Nil(t, performHttpRequest(bearer).err)
+
+	wrong := RequestConfig{Id: 1, Url: server.URL, RequestType: "GET", ResponseTime: 100, Auth: &AuthConfig{Type: AuthBasic, Username: "monitor", Password: "wrong"}}
+	assert.Equal(t, http.StatusUnauthorized, performHttpRequest(wrong).errorInfo.ResponseCode)
+}
+
+func TestDigestAuth(t *testing.T) {
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	var body string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := parseDigestChallenge(strings.TrimPrefix(r.Header.Get(Authorization), "Digest "))
+		ha1 := h("monitor:statusok:secret")
+		ha2 := h(r.Method + ":" + params["uri"])
+		expected := h(ha1 + ":n1:" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+		if params["response"] != expected || params["opaque"] != "o1" {
+			w.Header().Set("WWW-Authenticate", `Digest realm="statusok", nonce="n1", qop="auth,auth-int", opaque="o1"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	requestConfig := RequestConfig{Id: 1, Url: server.URL + "/status?full=1", RequestType: "POST", ResponseTime: 100, Body: "ping",
+		Auth: &AuthConfig{Type: AuthDigest, Username: "monitor", Password: "secret"}}
+	assert.Nil(t, performHttpRequest(requestConfig).err)
+	assert.Equal(t, "ping", body)
+
+	requestConfig.Auth = &AuthConfig{Type: AuthDigest, Username: "monitor", Password: "wrong"}
+	assert.Equal(t, http.StatusUnauthorized, performHttpRequest(requestConfig).errorInfo.ResponseCode)
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	tokensIssued := 0
+	expiresIn := 3600
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, _ := r.BasicAuth()
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read write" || clientId != "statusok" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		tokensIssued++
+		fmt.Fprintf(w, `{"access_token":"token%d","token_type":"bearer","expires_in":%d}`, tokensIssued, expiresIn)
+	}))
+	defer tokenServer.Close()
+
+	var lastToken string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastToken = r.Header.Get(Authorization)
+	}))
+	defer server.Close()
+
+	auth := &AuthConfig{Type: AuthOAuth2, TokenUrl: tokenServer.URL, ClientId: "statusok", ClientSecret: "secret", Scopes: []string{"read", "write"}}
+	assert.Nil(t, auth.Validate())
+	requestConfig := RequestConfig{Id: 1, Url: server.URL, RequestType: "GET", ResponseTime: 100, Auth: auth}
+
+	// the token is cached
+	assert.Nil(t, performHttpRequest(requestConfig).err)
+	assert.Nil(t, performHttpRequest(requestConfig).err)
+	assert.Equal(t, "Bearer token1", lastToken)
+	assert.Equal(t, 1, tokensIssued)
+
+	// a token expiring within refreshBefore is fetched again
+	expiresIn = 30
+	auth.invalidate()
+	assert.Nil(t, performHttpRequest(requestConfig).err)
+	assert.Nil(t, performHttpRequest(requestConfig).err)
+	assert.Equal(t, "Bearer token3", lastToken)
+	assert.Equal(t, 3, tokensIssued)
+}
+
+func TestOAuth2TokenFetchFailure(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer tokenServer.Close()
+
+	requestConfig := RequestConfig{Id: 1, Url: tokenServer.URL, RequestType: "GET", ResponseTime: 100,
+		Auth: &AuthConfig{Type: AuthOAuth2, TokenUrl: tokenServer.URL, ClientId: "statusok"}}
+	result := performHttpRequest(requestConfig)
+
+	assert.NotNil(t, result.errorInfo)
+	assert.Equal(t, model.CategoryTokenFetch, result.errorInfo.Category)
+	assert.EqualError(t, result.err, "Token endpoint returned response code 401")
+	assert.True(t, result.transient)
+}
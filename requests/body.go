@@ -0,0 +1,123 @@
+package requests
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	TextContentType   = "text/plain; charset=utf-8"
+	BinaryContentType = "application/octet-stream"
+)
+
+// MultipartBody is sent as multipart/form-data. Files maps field names to file paths.
+type MultipartBody struct {
+	Fields map[string]string `json:"fields"`
+	Files  map[string]string `json:"files"`
+}
+
+// check that at most one kind of body is given and all files exist
+func (requestConfig *RequestConfig) validateBody() error {
+	count := 0
+	for _, given := range []bool{len(requestConfig.FormParams) != 0, len(requestConfig.Body) != 0, len(requestConfig.JsonBody) != 0, len(requestConfig.BodyFile) != 0, requestConfig.Multipart != nil} {
+		if given {
+			count++
+		}
+	}
+	if count > 1 {
+		return errors.New("Only one of formParams, body, jsonBody, bodyFile or multipart can be given")
+	}
+
+	if len(requestConfig.BodyFile) != 0 {
+		if _, err := os.Stat(requestConfig.BodyFile); err != nil {
+			return fmt.Errorf("BodyFile cannot be read: %s", err)
+		}
+	}
+
+	if requestConfig.Multipart != nil {
+		if len(requestConfig.Headers[ContentType]) != 0 {
+			return errors.New("Content-Type header cannot be given for multipart, it is set automatically")
+		}
+		for field, file := range requestConfig.Multipart.Files {
+			if _, err := os.Stat(file); err != nil {
+				return fmt.Errorf("File for multipart field %s cannot be read: %s", field, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Creates the body given by body, jsonBody, bodyFile or multipart and its default content type.
+// Returns a nil body if none of them is given.
+func getRequestBody(requestConfig RequestConfig) (io.Reader, string, error) {
+	switch {
+	case len(requestConfig.Body) != 0:
+		return bytes.NewBufferString(requestConfig.Body), TextContentType, nil
+
+	case len(requestConfig.JsonBody) != 0:
+		return bytes.NewReader(requestConfig.JsonBody), JsonContentType, nil
+
+	case len(requestConfig.BodyFile) != 0:
+		data, err := ioutil.ReadFile(requestConfig.BodyFile)
+		if err != nil {
+			return nil, "", err
+		}
+		contentType := mime.TypeByExtension(filepath.Ext(requestConfig.BodyFile))
+		if len(contentType) == 0 {
+			contentType = BinaryContentType
+		}
+		return bytes.NewReader(data), contentType, nil
+
+	case requestConfig.Multipart != nil:
+		return getMultipartBody(*requestConfig.Multipart)
+	}
+
+	return nil, "", nil
+}
+
+func getMultipartBody(multipartBody MultipartBody) (io.Reader, string, error) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	for _, field := range sortedKeys(multipartBody.Fields) {
+		if err := writer.WriteField(field, multipartBody.Fields[field]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, field := range sortedKeys(multipartBody.Files) {
+		data, err := ioutil.ReadFile(multipartBody.Files[field])
+		if err != nil {
+			return nil, "", err
+		}
+		part, err := writer.CreateFormFile(field, filepath.Base(multipartBody.Files[field]))
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err = part.Write(data); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+package requests
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBody(t *testing.T) {
+	dir, err := ioutil.TempDir("", "body")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "body.json")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`{"ping":true}`), 0644))
+
+	valid := []RequestConfig{
+		{Url: "http://test.com", RequestType: "POST", ResponseTime: 100, Body: "ping"},
+		{Url: "http://test.com", RequestType: "POST", ResponseTime: 100, BodyFile: file},
+		{Url: "http://test.com", RequestType: "POST", ResponseTime: 100, Multipart: &MultipartBody{Files: map[string]string{"file": file}}},
+	}
+	for _, requestConfig := range valid {
+		assert.Nil(t, requestConfig.Validate())
+	}
+
+	invalid := []RequestConfig{
+		{Url: "http://test.com", RequestType: "POST", ResponseTime: 100, Body: "ping", JsonBody: json.RawMessage(`{}`)},
+		{Url: "http://test.com", RequestType: "POST", ResponseTime: 100, Body: "ping", FormParams: map[string]string{"a": "b"}},
+		{Url: "http://test.com", RequestType: "POST", ResponseTime: 100, BodyFile: filepath.Join(dir, "missing")},
+		{Url: "http://test.com", RequestType: "POST", ResponseTime: 100, Multipart: &MultipartBody{Files: map[string]string{"file": filepath.Join(dir, "missing")}}},
+		{Url: "http://test.com", RequestType: "POST", ResponseTime: 100, Headers: map[string]string{ContentType: "text/plain"}, Multipart: &MultipartBody{}},
+	}
+	for _, requestConfig := range invalid {
+		assert.Error(t, requestConfig.Validate())
+	}
+}
+
+func TestRequestBodies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "body")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "body.json")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`{"ping":true}`), 0644))
+
+	var contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get(ContentType)
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		requestConfig RequestConfig
+		contentType   string
+		body          string
+	}{
+		{RequestConfig{Body: "ping"}, TextContentType, "ping"},
+		{RequestConfig{Body: "ping", Headers: map[string]string{ContentType: "application/xml"}}, "application/xml", "ping"},
+		{RequestConfig{JsonBody: json.RawMessage(`{"query":"{ status }"}`)}, JsonContentType, `{"query":"{ status }"}`},
+		{RequestConfig{BodyFile: file}, JsonContentType, `{"ping":true}`},
+	}
+
+	for _, test := range tests {
+		requestConfig := test.requestConfig
+		requestConfig.Id = 1
+		requestConfig.Url = server.URL
+		requestConfig.RequestType = "POST"
+		requestConfig.ResponseTime = 100
+
+		assert.Nil(t, PerformRequest(requestConfig, nil))
+		assert.Contains(t, contentType, test.contentType)
+		assert.Equal(t, test.body, body)
+	}
+}
+
+func TestMultipartBody(t *testing.T) {
+	dir, err := ioutil.TempDir("", "body")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "sample.txt")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("sample"), 0644))
+
+	var description, fileName, fileContent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		description = r.FormValue("description")
+		part, header, err := r.FormFile("attachment")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer part.Close()
+		fileName = header.Filename
+		data, _ := ioutil.ReadAll(part)
+		fileContent = string(data)
+	}))
+	defer server.Close()
+
+	requestConfig := RequestConfig{Id: 1, Url: server.URL, RequestType: "POST", ResponseTime: 100,
+		Multipart: &MultipartBody{
+			Fields: map[string]string{"description": "health check"},
+			Files:  map[string]string{"attachment": file},
+		}}
+
+	assert.Nil(t, PerformRequest(requestConfig, nil))
+	assert.Equal(t, "health check", description)
+	assert.Equal(t, "sample.txt", fileName)
+	assert.Equal(t, "sample", fileContent)
+}
@@ -0,0 +1,25 @@
+package requests
+
+import (
+	"crypto/tls"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Saves the days until the server certificate expires as metric certExpiresInDays and returns an error
+// if it expires within certExpiryDays. Used by all checks connecting with TLS.
+func checkCertificateExpiry(requestConfig RequestConfig, state *tls.ConnectionState, metrics map[string]float64) error {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	certificate := state.PeerCertificates[0]
+	days := math.Floor(time.Until(certificate.NotAfter).Hours() / 24)
+	metrics["certExpiresInDays"] = days
+
+	if requestConfig.CertExpiryDays > 0 && days < float64(requestConfig.CertExpiryDays) {
+		return fmt.Errorf("Certificate of %s expires in %.0f days on %s", certificate.Subject.CommonName, days, certificate.NotAfter.Format("2006-01-02"))
+	}
+	return nil
+}
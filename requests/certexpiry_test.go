@@ -0,0 +1,39 @@
+package requests
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"statusok/model"
+	"statusok/tlsutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpCertificateExpiry(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "certexpiry")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.Nil(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	requestConfig := RequestConfig{Id: 1, Url: server.URL, RequestType: http.MethodGet, TLS: &tlsutil.Config{CaFile: caFile}, CertExpiryDays: 30, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Greater(t, result.requestInfo.Metrics["certExpiresInDays"], float64(30))
+
+	// the test certificate is valid for decades
+	requestConfig.CertExpiryDays = 100000
+	result = performAttempt(requestConfig)
+	assert.Error(t, result.err)
+	assert.Equal(t, model.CategoryCertificateExpiry, result.errorInfo.Category)
+	assert.Equal(t, http.StatusOK, result.errorInfo.ResponseCode)
+}
@@ -0,0 +1,54 @@
+package requests
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"statusok/model"
+	"strings"
+	"syscall"
+)
+
+// Returns the category of an error returned by performing a request
+func classifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return model.CategoryDnsFailure
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return model.CategoryTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return model.CategoryTimeout
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return model.CategoryConnectionRefused
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return model.CategoryConnectionReset
+	}
+
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var certificateInvalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &unknownAuthorityErr) || errors.As(err, &certificateInvalidErr) || errors.As(err, &hostnameErr) {
+		return model.CategoryCertificateInvalid
+	}
+
+	var recordHeaderErr tls.RecordHeaderError
+	if errors.As(err, &recordHeaderErr) || strings.Contains(err.Error(), "tls: ") ||
+		strings.Contains(err.Error(), "server gave HTTP response to HTTPS client") {
+		return model.CategoryTlsHandshake
+	}
+
+	return model.CategoryRequestFailed
+}
@@ -0,0 +1,61 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"statusok/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	assert.Equal(t, "", classifyError(nil))
+	assert.Equal(t, model.CategoryDnsFailure, classifyError(fmt.Errorf("wrapped: %w", &net.DNSError{Err: "no such host", Name: "test.invalid"})))
+	assert.Equal(t, model.CategoryRequestFailed, classifyError(errors.New("something else")))
+}
+
+func TestClassifyRequestErrors(t *testing.T) {
+	get := func(client *http.Client, url string) error {
+		_, err := client.Get(url)
+		return err
+	}
+
+	t.Run("connection refused", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		address := listener.Addr().String()
+		listener.Close()
+
+		assert.Equal(t, model.CategoryConnectionRefused, classifyError(get(http.DefaultClient, "http://"+address)))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		defer server.Close()
+
+		client := &http.Client{Timeout: 50 * time.Millisecond}
+		assert.Equal(t, model.CategoryTimeout, classifyError(get(client, server.URL)))
+	})
+
+	t.Run("certificate invalid", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		assert.Equal(t, model.CategoryCertificateInvalid, classifyError(get(&http.Client{}, server.URL)))
+	})
+
+	t.Run("tls handshake", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		httpsUrl := "https://" + server.Listener.Addr().String()
+		assert.Equal(t, model.CategoryTlsHandshake, classifyError(get(&http.Client{}, httpsUrl)))
+	})
+}
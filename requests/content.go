@@ -0,0 +1,280 @@
+package requests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"statusok/model"
+	"statusok/state"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	ContentAlertOnChange = "change"
+	ContentAlertOnStale  = "stale"
+)
+
+// ContentConfig detects changes of the response body of a http check. The body is normalized by
+// removing the regions matching Ignore and the elements matching IgnoreSelectors and collapsing
+// whitespace, then hashed. With alertOn change the check fails when the hash differs from the
+// baseline, with alertOn stale when it stayed the same for longer than MaxAge.
+type ContentConfig struct {
+	AlertOn         string           `json:"alertOn"`
+	Ignore          []string         `json:"ignore"`
+	_ignore         []*regexp.Regexp `json:"-"`
+	IgnoreSelectors []string         `json:"ignoreSelectors"`
+	_selectors      []selector       `json:"-"`
+	MaxAge          string           `json:"maxAge"`
+	_maxAge         time.Duration    `json:"-"`
+}
+
+// selector is a simple CSS selector like div, #clock, .ad or span.time
+type selector struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+// contentBaseline is the hash of the content of a check and since when it is unchanged
+type contentBaseline struct {
+	Hash  string    `json:"hash"`
+	Rules string    `json:"rules"` // fingerprint of the rules the hash was taken with
+	Since time.Time `json:"since"`
+}
+
+var (
+	contentMutex     sync.Mutex
+	contentBaselines = make(map[string]*contentBaseline) // baselines by check key
+	contentChanges   = make(map[string]*contentBaseline) // changed content by check key until the change is notified
+)
+
+// Loads the content baselines from the state file and saves them there on every change,
+// so changes while StatusOk was stopped are still detected.
+func LoadContentBaselines() error {
+	contentMutex.Lock()
+	defer contentMutex.Unlock()
+
+	contentBaselines = make(map[string]*contentBaseline)
+	contentChanges = make(map[string]*contentBaseline)
+	return state.Get(state.SectionContent, &contentBaselines)
+}
+
+// Parses a compound selector of a tag name, #id and .class parts
+func parseSelector(text string) (selector, error) {
+	var s selector
+	rest := strings.TrimSpace(text)
+	if len(rest) == 0 || strings.ContainsAny(rest, " >+~[]:*,") {
+		return s, fmt.Errorf("Selector %q is not supported, use a tag, #id or .class", text)
+	}
+	for len(rest) != 0 {
+		end := strings.IndexAny(rest[1:], "#.") + 1
+		if end == 0 {
+			end = len(rest)
+		}
+		part := rest[:end]
+		rest = rest[end:]
+		switch part[0] {
+		case '#':
+			s.id = part[1:]
+		case '.':
+			s.classes = append(s.classes, part[1:])
+		default:
+			s.tag = strings.ToLower(part)
+		}
+		if part == "#" || part == "." {
+			return s, fmt.Errorf("Selector %q has an empty name", text)
+		}
+	}
+	return s, nil
+}
+
+func (s selector) matches(node *html.Node) bool {
+	if node.Type != html.ElementNode || len(s.tag) != 0 && node.Data != s.tag {
+		return false
+	}
+	var id string
+	var classes []string
+	for _, attr := range node.Attr {
+		switch attr.Key {
+		case "id":
+			id = attr.Val
+		case "class":
+			classes = strings.Fields(attr.Val)
+		}
+	}
+	if len(s.id) != 0 && id != s.id {
+		return false
+	}
+	for _, class := range s.classes {
+		found := false
+		for _, c := range classes {
+			found = found || c == class
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// check the alerting mode and the rules of the content check
+func (content *ContentConfig) Validate() error {
+	switch content.AlertOn {
+	case "":
+		content.AlertOn = ContentAlertOnChange
+	case ContentAlertOnChange, ContentAlertOnStale:
+	default:
+		return fmt.Errorf("Unknown alertOn %s of content, use %s or %s", content.AlertOn, ContentAlertOnChange, ContentAlertOnStale)
+	}
+
+	var err error
+	if content.AlertOn == ContentAlertOnStale {
+		if len(content.MaxAge) == 0 {
+			return errors.New("MaxAge of content cannot be empty with alertOn stale")
+		}
+		if content._maxAge, err = time.ParseDuration(content.MaxAge); err != nil || content._maxAge <= 0 {
+			return fmt.Errorf("MaxAge of content is invalid %s", content.MaxAge)
+		}
+	} else if len(content.MaxAge) != 0 {
+		return errors.New("MaxAge of content can only be given with alertOn stale")
+	}
+
+	content._ignore = nil
+	for _, pattern := range content.Ignore {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("Ignore of content has an invalid regular expression: %s", err)
+		}
+		content._ignore = append(content._ignore, re)
+	}
+	content._selectors = nil
+	for _, text := range content.IgnoreSelectors {
+		s, err := parseSelector(text)
+		if err != nil {
+			return err
+		}
+		content._selectors = append(content._selectors, s)
+	}
+	return nil
+}
+
+// Removes the ignored elements and regions from the body and collapses whitespace
+func (content *ContentConfig) normalize(body []byte) ([]byte, error) {
+	if len(content._selectors) != 0 {
+		document, err := html.Parse(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		content.removeElements(document)
+		var buf bytes.Buffer
+		if err := html.Render(&buf, document); err != nil {
+			return nil, err
+		}
+		body = buf.Bytes()
+	}
+	for _, re := range content._ignore {
+		body = re.ReplaceAll(body, nil)
+	}
+	return bytes.Join(bytes.Fields(body), []byte(" ")), nil
+}
+
+func (content *ContentConfig) removeElements(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		removed := false
+		for _, s := range content._selectors {
+			if s.matches(child) {
+				node.RemoveChild(child)
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			content.removeElements(child)
+		}
+		child = next
+	}
+}
+
+// Identifies the baseline of a check across restarts, where ids may change with the config
+func contentKey(requestConfig RequestConfig) string {
+	if len(requestConfig.Name) != 0 {
+		return requestConfig.Name
+	}
+	return requestConfig.RequestType + " " + requestConfig.Url
+}
+
+// Compares the hash of the normalized body with the baseline of the check and returns the category
+// of a failure. The time the content is unchanged is saved as metric contentAgeSeconds. A changed
+// content only becomes the new baseline with acceptContentChange, so a change is reported until
+// a notification about it was sent.
+func checkContent(requestConfig RequestConfig, body []byte, now time.Time, metrics map[string]float64) (string, error) {
+	content := requestConfig.Content
+	normalized, err := content.normalize(body)
+	if err != nil {
+		return model.CategoryBodyRead, fmt.Errorf("Normalizing the content failed: %s", err)
+	}
+	sum := sha256.Sum256(normalized)
+	hash := hex.EncodeToString(sum[:])
+	rules := strings.Join(append(append([]string{}, content.Ignore...), content.IgnoreSelectors...), "\n")
+
+	contentMutex.Lock()
+	defer contentMutex.Unlock()
+
+	key := contentKey(requestConfig)
+	baseline := contentBaselines[key]
+	if baseline != nil && baseline.Rules == rules && baseline.Hash != hash && content.AlertOn == ContentAlertOnChange {
+		// keep the baseline until the change is notified, e.g. after a maintenance
+		change := contentChanges[key]
+		if change == nil || change.Hash != hash {
+			change = &contentBaseline{Hash: hash, Rules: rules, Since: now}
+			contentChanges[key] = change
+		}
+		metrics["contentAgeSeconds"] = float64(int64(now.Sub(change.Since).Seconds()))
+		return model.CategoryContentChanged, fmt.Errorf("Content changed from %s to %s after %s unchanged", baseline.Hash[:12], hash[:12], change.Since.Sub(baseline.Since).Truncate(time.Second))
+	}
+	delete(contentChanges, key)
+
+	if baseline == nil || baseline.Rules != rules || baseline.Hash != hash {
+		// first check, changed rules or a change with alertOn stale start a new baseline without alert
+		contentBaselines[key] = &contentBaseline{Hash: hash, Rules: rules, Since: now}
+		metrics["contentAgeSeconds"] = 0
+		saveContentBaselines()
+		return "", nil
+	}
+
+	age := now.Sub(baseline.Since)
+	metrics["contentAgeSeconds"] = float64(int64(age.Seconds()))
+	if content.AlertOn == ContentAlertOnStale && age > content._maxAge {
+		return model.CategoryContentStale, fmt.Errorf("Content did not change for %s, maxAge is %s", age.Truncate(time.Second), content.MaxAge)
+	}
+	return "", nil
+}
+
+// Makes the changed content of the check the new baseline after the change was notified
+func acceptContentChange(requestConfig RequestConfig) {
+	contentMutex.Lock()
+	defer contentMutex.Unlock()
+
+	key := contentKey(requestConfig)
+	change := contentChanges[key]
+	if change == nil {
+		return
+	}
+	delete(contentChanges, key)
+	contentBaselines[key] = change
+	saveContentBaselines()
+}
+
+func saveContentBaselines() {
+	if err := state.Save(state.SectionContent, contentBaselines); err != nil {
+		fmt.Printf("Saving content baselines to %s failed: %s\n", state.FileName(), err)
+	}
+}
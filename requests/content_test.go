@@ -0,0 +1,177 @@
+package requests
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"statusok/model"
+	"statusok/state"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentNormalize(t *testing.T) {
+	content := &ContentConfig{
+		Ignore:          []string{`csrf=[0-9a-f]+`},
+		IgnoreSelectors: []string{"#clock", "div.ad", "script"},
+	}
+	assert.Nil(t, content.Validate())
+	assert.Equal(t, ContentAlertOnChange, content.AlertOn)
+
+	first, err := content.normalize([]byte(`<html><body><h1>News</h1>
+		<span id="clock">12:00</span><div class="ad banner">Buy</div><div>csrf=ab12</div><script>var t=1</script></body></html>`))
+	assert.Nil(t, err)
+	second, err := content.normalize([]byte(`<html><body><h1>News</h1> <span id="clock">12:05</span>
+		<div class="banner ad">Sell</div><div>csrf=ff00</div><script>var t=2</script></body></html>`))
+	assert.Nil(t, err)
+	assert.Equal(t, string(first), string(second))
+	assert.NotContains(t, string(first), "12:00")
+
+	for _, invalid := range []*ContentConfig{
+		{AlertOn: "always"},
+		{AlertOn: ContentAlertOnStale},
+		{AlertOn: ContentAlertOnStale, MaxAge: "soon"},
+		{MaxAge: "1h"},
+		{Ignore: []string{"("}},
+		{IgnoreSelectors: []string{"div > p"}},
+		{IgnoreSelectors: []string{"div."}},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func resetContentBaselines() {
+	state.Load("")
+	LoadContentBaselines()
+}
+
+func TestCheckContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "content")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "state.json")
+	assert.Nil(t, state.Load(stateFile))
+	assert.Nil(t, LoadContentBaselines())
+	defer resetContentBaselines()
+
+	requestConfig := RequestConfig{Name: "homepage", Url: "https://mywebsite.com", RequestType: "GET", Content: &ContentConfig{}}
+	assert.Nil(t, requestConfig.Content.Validate())
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	metrics := make(map[string]float64)
+
+	_, err = checkContent(requestConfig, []byte("<p>Welcome</p>"), start, metrics)
+	assert.Nil(t, err)
+	_, err = checkContent(requestConfig, []byte("<p>Welcome</p>\n"), start.Add(time.Hour), metrics)
+	assert.Nil(t, err)
+	assert.Equal(t, 3600.0, metrics["contentAgeSeconds"])
+
+	// the baseline is kept across restarts
+	assert.Nil(t, state.Load(stateFile))
+	assert.Nil(t, LoadContentBaselines())
+	category, err := checkContent(requestConfig, []byte("<p>Hacked</p>"), start.Add(2*time.Hour), metrics)
+	assert.Contains(t, err.Error(), "after 2h0m0s unchanged")
+	assert.Equal(t, model.CategoryContentChanged, category)
+	assert.Equal(t, 0.0, metrics["contentAgeSeconds"])
+
+	// the change is reported until it was notified
+	_, err = checkContent(requestConfig, []byte("<p>Hacked</p>"), start.Add(3*time.Hour), metrics)
+	assert.Contains(t, err.Error(), "after 2h0m0s unchanged")
+	assert.Equal(t, 3600.0, metrics["contentAgeSeconds"])
+	data, err := ioutil.ReadFile(stateFile)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "2024-05-01T14:00:00Z")
+
+	acceptContentChange(requestConfig)
+	_, err = checkContent(requestConfig, []byte("<p>Hacked</p>"), start.Add(4*time.Hour), metrics)
+	assert.Nil(t, err)
+	assert.Equal(t, 7200.0, metrics["contentAgeSeconds"])
+
+	// changed rules start a new baseline
+	requestConfig.Content = &ContentConfig{Ignore: []string{"Hacked"}}
+	assert.Nil(t, requestConfig.Content.Validate())
+	_, err = checkContent(requestConfig, []byte("<p>Welcome</p>"), start.Add(4*time.Hour), metrics)
+	assert.Nil(t, err)
+
+	requestConfig.Content = &ContentConfig{AlertOn: ContentAlertOnStale, MaxAge: "6h"}
+	assert.Nil(t, requestConfig.Content.Validate())
+	requestConfig.Name = "news"
+	_, err = checkContent(requestConfig, []byte("<p>Old news</p>"), start, metrics)
+	assert.Nil(t, err)
+	_, err = checkContent(requestConfig, []byte("<p>Old news</p>"), start.Add(5*time.Hour), metrics)
+	assert.Nil(t, err)
+	category, err = checkContent(requestConfig, []byte("<p>Old news</p>"), start.Add(7*time.Hour), metrics)
+	assert.EqualError(t, err, "Content did not change for 7h0m0s, maxAge is 6h")
+	assert.Equal(t, model.CategoryContentStale, category)
+	_, err = checkContent(requestConfig, []byte("<p>Fresh news</p>"), start.Add(8*time.Hour), metrics)
+	assert.Nil(t, err)
+
+	data, err = ioutil.ReadFile(stateFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"news"`)
+	files, _ := ioutil.ReadDir(filepath.Dir(stateFile))
+	assert.Len(t, files, 1)
+}
+
+func TestHttpContentChange(t *testing.T) {
+	defer resetContentBaselines()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<h1>" + r.URL.Query().Get("title") + "</h1>"))
+	}))
+	defer server.Close()
+
+	// the name identifies the baseline, not the url
+	requestConfig := RequestConfig{Id: 1, Name: "homepage", Url: server.URL + "?title=Welcome", RequestType: "GET", ResponseTime: 100, Content: &ContentConfig{}}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Contains(t, result.requestInfo.Metrics, "contentAgeSeconds")
+
+	requestConfig.Url = server.URL + "?title=Defaced"
+	result = performAttempt(requestConfig)
+	assert.Equal(t, model.CategoryContentChanged, result.errorInfo.Category)
+	assert.Equal(t, "<h1>Defaced</h1>", result.errorInfo.ResponseBody)
+	assert.False(t, result.transient)
+
+	// without a notification the change is reported again
+	result = performAttempt(requestConfig)
+	assert.Equal(t, model.CategoryContentChanged, result.errorInfo.Category)
+
+	grpc := RequestConfig{Type: TypeGrpc, Url: server.URL, Content: &ContentConfig{}, ResponseTime: 100}
+	assert.Error(t, grpc.Validate())
+}
+
+func TestStartupProbeContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<h1>Defaced</h1>"))
+	}))
+	defer server.Close()
+
+	// baseline taken before StatusOk was stopped
+	dir, err := ioutil.TempDir("", "content")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "state.json")
+	saved := `{"content":{"homepage":{"hash":"` + strings.Repeat("ab", 32) + `","rules":"","since":"2024-05-01T12:00:00Z"}}}`
+	assert.Nil(t, ioutil.WriteFile(stateFile, []byte(saved), 0600))
+	assert.Nil(t, state.Load(stateFile))
+	assert.Nil(t, LoadContentBaselines())
+	defer resetContentBaselines()
+
+	requestConfig := RequestConfig{Id: 1, Name: "homepage", Url: server.URL, RequestType: "GET", ResponseTime: 1000, Content: &ContentConfig{}}
+	assert.Nil(t, requestConfig.Validate())
+
+	// the startup probe neither fails nor takes the changed content as baseline
+	RequestsInit([]RequestConfig{requestConfig}, 0)
+	data, err := ioutil.ReadFile(stateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, saved, string(data))
+
+	result := performAttempt(requestConfig)
+	assert.Equal(t, model.CategoryContentChanged, result.errorInfo.Category)
+	assert.Contains(t, result.err.Error(), "Content changed from abababababab")
+}
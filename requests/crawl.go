@@ -0,0 +1,340 @@
+package requests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"statusok/database"
+	"statusok/model"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	DefaultCrawlMaxDepth = 2
+	DefaultCrawlMaxPages = 100
+
+	// referrers listed for each broken link
+	maxCrawlReferrers = 3
+)
+
+// CrawlConfig limits the pages a crawl check follows. Links of the start url and the pages linked
+// from it are followed up to MaxDepth links away, at most MaxPages pages are requested.
+// Links matching one of the regular expressions of Exclude are not requested.
+type CrawlConfig struct {
+	MaxDepth int              `json:"maxDepth"`
+	MaxPages int              `json:"maxPages"`
+	Exclude  []string         `json:"exclude"`
+	_exclude []*regexp.Regexp `json:"-"`
+}
+
+// check the url and the limits of a crawl check, the start url is requested like a http check
+func (requestConfig *RequestConfig) validateCrawl() error {
+	if len(requestConfig.Url) == 0 {
+		return errors.New("Invalid Url")
+	}
+	if target, err := url.Parse(requestConfig.Url); err != nil || target.Scheme != "http" && target.Scheme != "https" {
+		return errors.New("Invalid Url")
+	}
+	if len(requestConfig.RequestType) == 0 {
+		requestConfig.RequestType = http.MethodGet
+	}
+
+	if requestConfig.Crawl == nil {
+		requestConfig.Crawl = &CrawlConfig{}
+	}
+	crawl := requestConfig.Crawl
+	if crawl.MaxDepth < 0 || crawl.MaxPages < 0 {
+		return errors.New("MaxDepth and maxPages of crawl cannot be negative")
+	}
+	if crawl.MaxDepth == 0 {
+		crawl.MaxDepth = DefaultCrawlMaxDepth
+	}
+	if crawl.MaxPages == 0 {
+		crawl.MaxPages = DefaultCrawlMaxPages
+	}
+	crawl._exclude = nil
+	for _, pattern := range crawl.Exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("Exclude of crawl has an invalid regular expression: %s", err)
+		}
+		crawl._exclude = append(crawl._exclude, re)
+	}
+	return nil
+}
+
+func (crawl *CrawlConfig) excluded(link string) bool {
+	for _, re := range crawl._exclude {
+		if re.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// attributes holding links by element
+var linkAttributes = map[string]string{
+	"a":      "href",
+	"area":   "href",
+	"link":   "href",
+	"img":    "src",
+	"script": "src",
+	"iframe": "src",
+}
+
+// Returns the links of the html page resolved against its url, without fragments
+func extractLinks(base *url.URL, body io.Reader) []*url.URL {
+	var links []*url.URL
+	tokenizer := html.NewTokenizer(body)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			attribute, ok := linkAttributes[token.Data]
+			if !ok {
+				continue
+			}
+			for _, attr := range token.Attr {
+				if attr.Key != attribute {
+					continue
+				}
+				link, err := base.Parse(strings.TrimSpace(attr.Val))
+				if err != nil || link.Scheme != "http" && link.Scheme != "https" {
+					continue
+				}
+				link.Fragment = ""
+				if len(link.Path) == 0 {
+					link.Path = "/"
+				}
+				links = append(links, link)
+			}
+		}
+	}
+}
+
+// crawlPage is a page to request with the pages linking to it
+type crawlPage struct {
+	url       *url.URL
+	referrers []string
+}
+
+// result of requesting a page, broken if it failed or returned 4xx or 5xx
+type crawlResult struct {
+	page    *crawlPage
+	broken  string
+	links   []*url.URL
+	skipped bool // not requested or canceled as the crawl reached its deadline
+}
+
+// Requests the page with the headers and auth of the check and returns its links if it is a html page
+func fetchCrawlPage(ctx context.Context, requestConfig RequestConfig, client *http.Client, page *crawlPage, parse bool) crawlResult {
+	result := crawlResult{page: page}
+	if ctx.Err() != nil {
+		result.skipped = true
+		return result
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, page.url.String(), nil)
+	if err != nil {
+		result.broken = "failed: " + err.Error()
+		return result
+	}
+	request.Header.Set(UserAgent, DefaultUserAgent)
+	AddHeaders(request, requestConfig.Headers)
+	if requestConfig.Auth != nil {
+		if err := requestConfig.Auth.apply(request, client); err != nil {
+			result.broken = "failed: " + err.Error()
+			return result
+		}
+	}
+
+	response, err := requestConfig.Auth.do(client, request)
+	if err != nil && ctx.Err() != nil {
+		result.skipped = true
+		return result
+	}
+	if err != nil {
+		if category := classifyError(err); category == model.CategoryTimeout {
+			result.broken = "timed out"
+		} else {
+			result.broken = "failed with " + category
+		}
+		return result
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		result.broken = fmt.Sprintf("returned %d", response.StatusCode)
+		return result
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get(ContentType))
+	if parse && mediaType == "text/html" {
+		result.links = extractLinks(response.Request.URL, response.Body)
+	}
+	return result
+}
+
+// Fetches the pages in parallel as far as the throttle has free slots. The first page and every page
+// without free slot is fetched by the check itself, which already holds a slot.
+func fetchCrawlPages(pages []*crawlPage, fetch func(page *crawlPage) crawlResult) []crawlResult {
+	results := make([]crawlResult, len(pages))
+	var wg sync.WaitGroup
+	for i, page := range pages {
+		select {
+		case throttle <- 1:
+			wg.Add(1)
+			go func(i int, page *crawlPage) {
+				defer func() {
+					<-throttle
+					wg.Done()
+				}()
+				results[i] = fetch(page)
+			}(i, page)
+		default:
+			results[i] = fetch(page)
+		}
+	}
+	wg.Wait()
+	return results
+}
+
+// Time by which the crawl has to finish, the next check is due then
+func crawlDeadline(requestConfig RequestConfig, start time.Time) time.Time {
+	if requestConfig._schedule != nil {
+		return requestConfig._schedule.Next(start)
+	}
+	return start.Add(requestConfig._checkEvery)
+}
+
+// requests the start url like a http check and follows the links to pages of the same origin level by
+// level up to maxDepth. Pages failing or returning 4xx or 5xx are listed with their referrers.
+// Pages not requested before the next check is due are skipped.
+// The number of requested pages and of broken links is saved as metrics pagesCrawled and brokenLinks.
+func performCrawlCheck(requestConfig RequestConfig) attemptResult {
+	ctx, cancel := context.WithDeadline(context.Background(), crawlDeadline(requestConfig, time.Now()))
+	defer cancel()
+
+	result := performHttpRequest(requestConfig)
+	if result.err != nil {
+		return result
+	}
+	client, err := newHttpClient(requestConfig)
+	if err != nil {
+		// Not able to create the client for the linked pages. Add Error to Database
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:           requestConfig.Id,
+				Url:          requestConfig.Url,
+				RequestType:  requestConfig.RequestType,
+				ResponseCode: 0,
+				ResponseBody: "",
+				Reason:       database.ErrCreateRequest,
+				Category:     model.CategoryInvalidRequest,
+				OtherInfo:    err.Error(),
+			},
+			err:     err,
+			elapsed: result.elapsed,
+		}
+	}
+
+	crawl := requestConfig.Crawl
+	start := *result.response.Request.URL
+	start.Fragment = ""
+	if len(start.Path) == 0 {
+		start.Path = "/"
+	}
+	origin := start.Scheme + "://" + start.Host
+	seen := map[string]*crawlPage{start.String(): {url: &start}}
+	requested := 1
+
+	// adds the links of a page to the next level, already known pages get the page as referrer
+	var next []*crawlPage
+	enqueue := func(referrer string, links []*url.URL) {
+		for _, link := range links {
+			key := link.String()
+			if page, ok := seen[key]; ok {
+				// the start page has no referrers
+				if n := len(page.referrers); n != 0 && n < maxCrawlReferrers && page.referrers[n-1] != referrer {
+					page.referrers = append(page.referrers, referrer)
+				}
+				continue
+			}
+			if link.Scheme+"://"+link.Host != origin || crawl.excluded(key) || requested >= crawl.MaxPages {
+				continue
+			}
+			page := &crawlPage{url: link, referrers: []string{referrer}}
+			seen[key] = page
+			next = append(next, page)
+			requested++
+		}
+	}
+	enqueue(start.String(), extractLinks(&start, bytes.NewReader(result.body)))
+
+	var broken []crawlResult
+	skipped := 0
+	for depth := 1; depth <= crawl.MaxDepth && len(next) != 0 && ctx.Err() == nil; depth++ {
+		level := next
+		next = nil
+		// links of the last level are only checked, not followed
+		parse := depth < crawl.MaxDepth
+		for _, pageResult := range fetchCrawlPages(level, func(page *crawlPage) crawlResult {
+			return fetchCrawlPage(ctx, requestConfig, client, page, parse)
+		}) {
+			if pageResult.skipped {
+				skipped++
+				continue
+			}
+			if len(pageResult.broken) != 0 {
+				broken = append(broken, pageResult)
+				continue
+			}
+			enqueue(pageResult.page.url.String(), pageResult.links)
+		}
+	}
+	// pages of the level the deadline was reached before
+	skipped += len(next)
+
+	metrics := result.requestInfo.Metrics
+	if metrics == nil {
+		metrics = make(map[string]float64)
+	}
+	metrics["pagesCrawled"] = float64(requested - skipped)
+	metrics["brokenLinks"] = float64(len(broken))
+	result.requestInfo.Metrics = metrics
+	if len(broken) == 0 {
+		return result
+	}
+
+	var lines []string
+	for _, pageResult := range broken {
+		lines = append(lines, fmt.Sprintf("%s %s, linked from %s", pageResult.page.url, pageResult.broken, strings.Join(pageResult.page.referrers, ", ")))
+	}
+	err = fmt.Errorf("%d broken links: %s", len(broken), strings.Join(lines, "; "))
+	return attemptResult{
+		errorInfo: &model.ErrorInfo{
+			Id:             requestConfig.Id,
+			Url:            requestConfig.Url,
+			RequestType:    requestConfig.RequestType,
+			ResponseCode:   result.requestInfo.ResponseCode,
+			ResponseTimeMs: result.requestInfo.ResponseTimeMs,
+			Headers:        result.headers,
+			Reason:         database.ErrBrokenLinks,
+			Category:       model.CategoryBrokenLinks,
+			OtherInfo:      strings.Join(lines, "\n"),
+			Metrics:        metrics,
+		},
+		err:     err,
+		elapsed: result.elapsed,
+	}
+}
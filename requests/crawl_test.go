@@ -0,0 +1,124 @@
+package requests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"statusok/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractLinks(t *testing.T) {
+	base, _ := url.Parse("https://mywebsite.com/blog/")
+	links := extractLinks(base, strings.NewReader(`<html><head><link rel="stylesheet" href="/style.css"></head>
+		<body><a href="post#comments">Post</a><a href="mailto:info@mywebsite.com">Mail</a><a href="javascript:void(0)">Menu</a>
+		<img src="https://cdn.mywebsite.com/logo.png"/><a name="top">Top</a></body></html>`))
+
+	var urls []string
+	for _, link := range links {
+		urls = append(urls, link.String())
+	}
+	assert.Equal(t, []string{"https://mywebsite.com/style.css", "https://mywebsite.com/blog/post", "https://cdn.mywebsite.com/logo.png"}, urls)
+}
+
+func TestValidateCrawl(t *testing.T) {
+	requestConfig := RequestConfig{Type: TypeCrawl, Url: "https://mywebsite.com", ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	assert.Equal(t, DefaultCrawlMaxDepth, requestConfig.Crawl.MaxDepth)
+	assert.Equal(t, DefaultCrawlMaxPages, requestConfig.Crawl.MaxPages)
+	assert.Equal(t, http.MethodGet, requestConfig.RequestType)
+
+	for _, invalid := range []RequestConfig{
+		{Type: TypeCrawl, Url: "ftp://mywebsite.com", ResponseTime: 100},
+		{Type: TypeCrawl, Url: "https://mywebsite.com", Crawl: &CrawlConfig{MaxDepth: -1}, ResponseTime: 100},
+		{Type: TypeCrawl, Url: "https://mywebsite.com", Crawl: &CrawlConfig{Exclude: []string{"("}}, ResponseTime: 100},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func TestCrawlCheck(t *testing.T) {
+	pages := map[string]string{
+		"/":      `<a href="/about">About</a><a href="/missing">Old</a><a href="https://external.example.com/gone">Partner</a><img src="/logo.png"><a href="/slow">Slow</a>`,
+		"/about": `<a href="/#top">Home</a><a href="/deep">Deep</a><a href="/missing">Old</a>`,
+		"/deep":  `<a href="/deeper">Deeper</a>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(300 * time.Millisecond)
+		}
+		page, ok := pages[r.URL.Path]
+		if !ok && r.URL.Path != "/slow" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set(ContentType, "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	// pages are fetched in parallel with the free slots of the throttle
+	previousThrottle := throttle
+	throttle = make(chan int, 3)
+	defer func() { throttle = previousThrottle }()
+
+	requestConfig := RequestConfig{Id: 1, Type: TypeCrawl, Url: server.URL, Timeout: "100ms", ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Equal(t, model.CategoryBrokenLinks, result.errorInfo.Category)
+	assert.Equal(t, strings.Join([]string{
+		server.URL + "/missing returned 404, linked from " + server.URL + "/, " + server.URL + "/about",
+		server.URL + "/logo.png returned 404, linked from " + server.URL + "/",
+		server.URL + "/slow timed out, linked from " + server.URL + "/",
+	}, "\n"), result.errorInfo.OtherInfo)
+	assert.Equal(t, 6.0, result.errorInfo.Metrics["pagesCrawled"])
+	assert.Equal(t, 3.0, result.errorInfo.Metrics["brokenLinks"])
+	assert.False(t, result.transient)
+	assert.Len(t, throttle, 0)
+
+	requestConfig.Crawl = &CrawlConfig{Exclude: []string{"/missing$", "/logo.png$", "/slow$"}}
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Equal(t, 3.0, result.requestInfo.Metrics["pagesCrawled"])
+
+	requestConfig.Crawl = &CrawlConfig{MaxDepth: 3, MaxPages: 2}
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Equal(t, 2.0, result.requestInfo.Metrics["pagesCrawled"])
+
+	requestConfig.Url = server.URL + "/gone"
+	result = performAttempt(requestConfig)
+	assert.Equal(t, model.CategoryUnexpectedStatus, result.errorInfo.Category)
+}
+
+func TestCrawlDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(ContentType, "text/html; charset=utf-8")
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/1">1</a><a href="/2">2</a><a href="/3">3</a><a href="/4">4</a><a href="/5">5</a>`))
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`<a href="/deeper` + r.URL.Path + `">Deeper</a>`))
+	}))
+	defer server.Close()
+
+	// without free slots the pages are fetched one after another
+	previousThrottle := throttle
+	throttle = make(chan int)
+	defer func() { throttle = previousThrottle }()
+
+	requestConfig := RequestConfig{Id: 1, Type: TypeCrawl, Url: server.URL, CheckEvery: "250ms", Timeout: "1s", ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	start := time.Now()
+	result := performAttempt(requestConfig)
+	assert.Less(t, int64(time.Since(start)), int64(400*time.Millisecond))
+	assert.Nil(t, result.err)
+	assert.Equal(t, 3.0, result.requestInfo.Metrics["pagesCrawled"])
+}
@@ -0,0 +1,77 @@
+package requests
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Check that every request in dependsOn exists, names are unique and there are no cycles
+func ValidateDependencies(reqs []RequestConfig) error {
+	byName := make(map[string]int)
+	for i, requestConfig := range reqs {
+		if len(requestConfig.Name) == 0 {
+			continue
+		}
+		if _, ok := byName[requestConfig.Name]; ok {
+			return fmt.Errorf("Request name %s is not unique", requestConfig.Name)
+		}
+		byName[requestConfig.Name] = i
+	}
+
+	for _, requestConfig := range reqs {
+		for _, parent := range requestConfig.DependsOn {
+			if _, ok := byName[parent]; !ok {
+				return fmt.Errorf("Request %s depends on unknown request %s", requestConfig.Url, parent)
+			}
+		}
+	}
+
+	// depth first search, a request visited again while on the current path is part of a cycle
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make([]int, len(reqs))
+	var path []string
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case onPath:
+			return fmt.Errorf("Dependency cycle found: %s -> %s", strings.Join(path, " -> "), reqs[i].Name)
+		case done:
+			return nil
+		}
+		state[i] = onPath
+		path = append(path, reqs[i].Name)
+		for _, parent := range reqs[i].DependsOn {
+			if err := visit(byName[parent]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = done
+		return nil
+	}
+
+	for i := range reqs {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the ids of the requests the given request depends on
+func GetDependencyIds(reqs []RequestConfig, requestConfig RequestConfig) []int {
+	ids := make([]int, 0, len(requestConfig.DependsOn))
+	for _, parent := range requestConfig.DependsOn {
+		for _, other := range reqs {
+			if len(other.Name) != 0 && other.Name == parent {
+				ids = append(ids, other.Id)
+			}
+		}
+	}
+	return ids
+}
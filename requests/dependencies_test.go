@@ -0,0 +1,52 @@
+package requests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDependencies(t *testing.T) {
+	reqs := []RequestConfig{
+		{Id: 1, Name: "gateway", Url: "http://gateway.test.com"},
+		{Id: 2, Name: "api", Url: "http://api.test.com", DependsOn: []string{"gateway"}},
+		{Id: 3, Url: "http://test.com", DependsOn: []string{"api", "gateway"}},
+	}
+
+	assert.Nil(t, ValidateDependencies(reqs))
+	assert.Equal(t, []int{2, 1}, GetDependencyIds(reqs, reqs[2]))
+	assert.Empty(t, GetDependencyIds(reqs, reqs[0]))
+}
+
+func TestValidateDependenciesErrors(t *testing.T) {
+	t.Run("unknown request", func(t *testing.T) {
+		err := ValidateDependencies([]RequestConfig{
+			{Name: "api", Url: "http://api.test.com", DependsOn: []string{"gateway"}},
+		})
+		assert.EqualError(t, err, "Request http://api.test.com depends on unknown request gateway")
+	})
+
+	t.Run("duplicate name", func(t *testing.T) {
+		err := ValidateDependencies([]RequestConfig{
+			{Name: "api", Url: "http://api.test.com"},
+			{Name: "api", Url: "http://api2.test.com"},
+		})
+		assert.EqualError(t, err, "Request name api is not unique")
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		err := ValidateDependencies([]RequestConfig{
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"c"}},
+			{Name: "c", DependsOn: []string{"a"}},
+		})
+		assert.EqualError(t, err, "Dependency cycle found: a -> b -> c -> a")
+	})
+
+	t.Run("depends on itself", func(t *testing.T) {
+		err := ValidateDependencies([]RequestConfig{
+			{Name: "a", DependsOn: []string{"a"}},
+		})
+		assert.EqualError(t, err, "Dependency cycle found: a -> a")
+	})
+}
@@ -0,0 +1,211 @@
+package requests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"statusok/database"
+	"statusok/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exit codes of Nagios plugins
+const (
+	ExitOk = iota
+	ExitWarning
+	ExitCritical
+	ExitUnknown
+)
+
+// ExecConfig is the command of an exec check, run with its arguments and
+// additional environment variables like a Nagios plugin
+type ExecConfig struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+}
+
+// check the command of an exec check, the command line is used as url if not given
+func (requestConfig *RequestConfig) validateExec() error {
+	if requestConfig.Exec == nil || len(requestConfig.Exec.Command) == 0 {
+		return errors.New("Command cannot be empty for a check of type exec")
+	}
+	if _, err := exec.LookPath(requestConfig.Exec.Command); err != nil {
+		return fmt.Errorf("Command cannot be run: %s", err)
+	}
+	if len(requestConfig.Url) == 0 {
+		requestConfig.Url = "exec://" + strings.Join(append([]string{requestConfig.Exec.Command}, requestConfig.Exec.Args...), " ")
+	}
+	if len(requestConfig.RequestType) == 0 {
+		requestConfig.RequestType = strings.ToUpper(TypeExec)
+	}
+	return nil
+}
+
+// Splits the output of a plugin into its status text and performance data
+func splitPluginOutput(output string) (string, string) {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	status := lines[0]
+	var perfdata []string
+	if i := strings.Index(status, "|"); i != -1 {
+		perfdata = append(perfdata, status[i+1:])
+		status = status[:i]
+	}
+
+	// long text may be followed by more performance data after a |
+	inPerfdata := false
+	for _, line := range lines[1:] {
+		if !inPerfdata {
+			i := strings.Index(line, "|")
+			if i == -1 {
+				continue
+			}
+			line, inPerfdata = line[i+1:], true
+		}
+		perfdata = append(perfdata, line)
+	}
+	return strings.TrimSpace(status), strings.Join(perfdata, " ")
+}
+
+// Parses performance data like 'disk /'=3.2GB;8;9;0;10 time=0.03s into values by label.
+// Units are dropped, invalid entries and labels of fields saved for every request are skipped.
+func parsePerfdata(perfdata string) map[string]float64 {
+	metrics := make(map[string]float64)
+	rest := strings.TrimSpace(perfdata)
+	for len(rest) != 0 {
+		var label string
+		if rest[0] == '\'' {
+			end := strings.Index(rest[1:], "'=")
+			if end == -1 {
+				break
+			}
+			label, rest = rest[1:end+1], rest[end+3:]
+		} else {
+			eq := strings.Index(rest, "=")
+			if eq == -1 {
+				break
+			}
+			label, rest = rest[:eq], rest[eq+1:]
+		}
+
+		end := strings.IndexAny(rest, " \t")
+		if end == -1 {
+			end = len(rest)
+		}
+		value := strings.SplitN(rest[:end], ";", 2)[0]
+		rest = strings.TrimSpace(rest[end:])
+
+		number := strings.TrimRightFunc(value, func(r rune) bool {
+			return !(r >= '0' && r <= '9' || r == '.')
+		})
+		if parsed, err := strconv.ParseFloat(number, 64); err == nil && len(label) != 0 && !database.IsReservedMetric(label) {
+			metrics[label] = parsed
+		}
+	}
+	return metrics
+}
+
+// runs the command of an exec check. Exit codes other than 0 fail the check, the
+// performance data of the output is saved as metrics and the exit code as response code
+func performExecCheck(requestConfig RequestConfig) attemptResult {
+	ctx := context.Background()
+	if requestConfig._timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestConfig._timeout)
+		defer cancel()
+	}
+
+	command := exec.Command(requestConfig.Exec.Command, requestConfig.Exec.Args...)
+	setProcessGroup(command)
+	command.Env = os.Environ()
+	for _, key := range sortedKeys(requestConfig.Exec.Env) {
+		command.Env = append(command.Env, key+"="+requestConfig.Exec.Env[key])
+	}
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+
+	start := time.Now()
+	runErr := command.Start()
+	if runErr == nil {
+		done := make(chan error, 1)
+		go func() { done <- command.Wait() }()
+		select {
+		case runErr = <-done:
+		case <-ctx.Done():
+			// processes forked by the plugin would keep the output open and block Wait
+			killProcessGroup(command)
+			runErr = <-done
+		}
+	}
+	elapsed := time.Since(start)
+
+	status, perfdata := splitPluginOutput(stdout.String())
+	metrics := parsePerfdata(perfdata)
+
+	exitCode := ExitOk
+	var reason error
+	category := ""
+	transient := false
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		exitCode, reason, category, transient = ExitUnknown, database.ErrTimeout, model.CategoryTimeout, true
+	case runErr != nil:
+		var exitErr *exec.ExitError
+		if !errors.As(runErr, &exitErr) {
+			exitCode, reason, category = ExitUnknown, database.ErrDoRequest, model.CategoryRequestFailed
+			status = runErr.Error()
+			break
+		}
+		switch exitErr.ExitCode() {
+		case ExitWarning:
+			exitCode, reason, category = ExitWarning, database.ErrCheckWarning, model.CategoryCheckWarning
+		case ExitCritical:
+			exitCode, reason, category = ExitCritical, database.ErrCheckCritical, model.CategoryCheckCritical
+		default:
+			exitCode, reason, category = ExitUnknown, database.ErrCheckUnknown, model.CategoryCheckUnknown
+		}
+	}
+
+	if reason != nil {
+		otherInfo := status
+		if stderr.Len() != 0 {
+			otherInfo = strings.TrimSpace(otherInfo + " " + strings.TrimSpace(stderr.String()))
+		}
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:             requestConfig.Id,
+				Url:            requestConfig.Url,
+				RequestType:    requestConfig.RequestType,
+				ResponseCode:   exitCode,
+				ResponseTimeMs: elapsed.Milliseconds(),
+				ResponseBody:   stdout.String(),
+				Reason:         reason,
+				Category:       category,
+				OtherInfo:      otherInfo,
+				Metrics:        metrics,
+			},
+			err:       fmt.Errorf("%s: %s", reason, status),
+			transient: transient,
+			elapsed:   elapsed,
+		}
+	}
+
+	return attemptResult{
+		requestInfo: model.RequestInfo{
+			Id:                   requestConfig.Id,
+			Url:                  requestConfig.Url,
+			RequestType:          requestConfig.RequestType,
+			ResponseCode:         exitCode,
+			ResponseTimeMs:       elapsed.Milliseconds(),
+			ExpectedResponseTime: requestConfig.ResponseTime,
+			Metrics:              metrics,
+		},
+		elapsed: elapsed,
+	}
+}
@@ -0,0 +1,112 @@
+package requests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"statusok/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writes a shell script acting as Nagios plugin
+func writePlugin(t *testing.T, dir string, script string) string {
+	file := filepath.Join(dir, "check_test")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("#!/bin/sh\n"+script), 0755))
+	return file
+}
+
+func TestParsePluginOutput(t *testing.T) {
+	status, perfdata := splitPluginOutput("DISK OK - free space: / 3326 MB (56%); | /=2643MB;5948;5958;0;5968\n/ 15272 MB (77%);\n/boot 68 MB (69%);\n/home 69357 MB (27%);| /boot=68MB;88;93;0;98\n/home=69357MB;253404;253409;0;253414\n")
+	assert.Equal(t, "DISK OK - free space: / 3326 MB (56%);", status)
+	assert.Equal(t, map[string]float64{"/": 2643, "/boot": 68, "/home": 69357}, parsePerfdata(perfdata))
+
+	metrics := parsePerfdata(`'time to first byte'=0.25s;1;2 size=1024B;;; load1=-0.5 invalid ratio=U;1;2`)
+	assert.Equal(t, map[string]float64{"time to first byte": 0.25, "size": 1024, "load1": -0.5}, metrics)
+
+	// labels cannot replace the fields saved for every request
+	assert.Equal(t, map[string]float64{"time": 0.1}, parsePerfdata(`responseTimeMs=5ms responseCode=0 time=0.1s`))
+
+	status, perfdata = splitPluginOutput("PING OK\n")
+	assert.Equal(t, "PING OK", status)
+	assert.Empty(t, parsePerfdata(perfdata))
+}
+
+func TestValidateExec(t *testing.T) {
+	requestConfig := RequestConfig{Type: TypeExec, ResponseTime: 100, Exec: &ExecConfig{Command: "sh", Args: []string{"-c", "exit 0"}}}
+	assert.Nil(t, requestConfig.Validate())
+	assert.Equal(t, "exec://sh -c exit 0", requestConfig.Url)
+	assert.Equal(t, "EXEC", requestConfig.RequestType)
+
+	for _, invalid := range []RequestConfig{
+		{Type: TypeExec, ResponseTime: 100},
+		{Type: TypeExec, ResponseTime: 100, Exec: &ExecConfig{Command: "/missing/check_disk"}},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func TestExecCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exec")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	plugin := writePlugin(t, dir, `echo "LOAD $1 - load average: $LOAD | load1=$LOAD;2;4;0"
+case $1 in OK) exit 0;; WARNING) exit 1;; CRITICAL) exit 2;; *) exit 3;; esac
+`)
+
+	tests := []struct {
+		state    string
+		code     int
+		category string
+	}{
+		{"OK", ExitOk, ""},
+		{"WARNING", ExitWarning, model.CategoryCheckWarning},
+		{"CRITICAL", ExitCritical, model.CategoryCheckCritical},
+		{"BROKEN", ExitUnknown, model.CategoryCheckUnknown},
+	}
+	for _, test := range tests {
+		requestConfig := RequestConfig{Id: 1, Type: TypeExec, ResponseTime: 100,
+			Exec: &ExecConfig{Command: plugin, Args: []string{test.state}, Env: map[string]string{"LOAD": "1.5"}}}
+		assert.Nil(t, requestConfig.Validate())
+		result := performAttempt(requestConfig)
+
+		if test.category == "" {
+			assert.Nil(t, result.errorInfo)
+			assert.Equal(t, test.code, result.requestInfo.ResponseCode)
+			assert.Equal(t, map[string]float64{"load1": 1.5}, result.requestInfo.Metrics)
+			continue
+		}
+		assert.NotNil(t, result.errorInfo, test.state)
+		assert.Equal(t, test.code, result.errorInfo.ResponseCode)
+		assert.Equal(t, test.category, result.errorInfo.Category)
+		assert.Equal(t, "LOAD "+test.state+" - load average: 1.5", result.errorInfo.OtherInfo)
+		assert.Equal(t, map[string]float64{"load1": 1.5}, result.errorInfo.Metrics)
+	}
+}
+
+func TestExecCheckTimeout(t *testing.T) {
+	requestConfig := RequestConfig{Id: 1, Type: TypeExec, ResponseTime: 100, Timeout: "100ms", Exec: &ExecConfig{Command: "sleep", Args: []string{"5"}}}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+
+	assert.NotNil(t, result.errorInfo)
+	assert.Equal(t, model.CategoryTimeout, result.errorInfo.Category)
+	assert.Equal(t, ExitUnknown, result.errorInfo.ResponseCode)
+	assert.True(t, result.transient)
+}
+
+func TestExecCheckTimeoutKillsChildren(t *testing.T) {
+	// the shell waits for sleep, which outlives the timeout
+	requestConfig := RequestConfig{Id: 1, Type: TypeExec, ResponseTime: 100, Timeout: "200ms", Exec: &ExecConfig{Command: "sh", Args: []string{"-c", "sleep 4; echo done"}}}
+	assert.Nil(t, requestConfig.Validate())
+
+	start := time.Now()
+	result := performAttempt(requestConfig)
+	assert.True(t, time.Since(start) < 2*time.Second, "took %s", time.Since(start))
+	assert.NotNil(t, result.errorInfo)
+	assert.Equal(t, model.CategoryTimeout, result.errorInfo.Category)
+	assert.Equal(t, "", result.errorInfo.ResponseBody)
+}
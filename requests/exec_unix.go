@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package requests
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Starts the command in its own process group, so processes it forks can be killed with it
+func setProcessGroup(command *exec.Cmd) {
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// Kills the command and all processes of its process group
+func killProcessGroup(command *exec.Cmd) {
+	syscall.Kill(-command.Process.Pid, syscall.SIGKILL)
+}
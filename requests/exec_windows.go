@@ -0,0 +1,10 @@
+package requests
+
+import "os/exec"
+
+func setProcessGroup(command *exec.Cmd) {}
+
+// Kills the command, processes it started keep running
+func killProcessGroup(command *exec.Cmd) {
+	command.Process.Kill()
+}
@@ -0,0 +1,308 @@
+package requests
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"statusok/database"
+	"statusok/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	GrpcContentType  = "application/grpc"
+	GrpcHealthMethod = "/grpc.health.v1.Health/Check"
+)
+
+// Serving status of the grpc health checking protocol
+const (
+	GrpcUnknown = iota
+	GrpcServing
+	GrpcNotServing
+	GrpcServiceUnknown
+)
+
+var grpcStatusNames = map[int]string{
+	GrpcUnknown:        "UNKNOWN",
+	GrpcServing:        "SERVING",
+	GrpcNotServing:     "NOT_SERVING",
+	GrpcServiceUnknown: "SERVICE_UNKNOWN",
+}
+
+// check the url of a grpc check, grpc://host:port for plaintext or grpcs://host:port for TLS
+func (requestConfig *RequestConfig) validateGrpc() error {
+	target, err := url.Parse(requestConfig.Url)
+	if err != nil || len(target.Host) == 0 {
+		return errors.New("Invalid Url")
+	}
+	if target.Scheme != "grpc" && target.Scheme != "grpcs" {
+		return fmt.Errorf("Invalid Url %s, use grpc://host:port or grpcs://host:port", requestConfig.Url)
+	}
+	if len(requestConfig.Proxy) != 0 {
+		return errors.New("Proxy cannot be given for a check of type grpc")
+	}
+	if len(requestConfig.RequestType) == 0 {
+		requestConfig.RequestType = strings.ToUpper(TypeGrpc)
+	}
+	return nil
+}
+
+// Creates a HTTP/2 transport for grpc checks, without TLS for grpc:// urls.
+// DialTLS cannot be cancelled, so connecting is bounded by the timeout of the check.
+func newGrpcTransport(requestConfig RequestConfig) (*http2.Transport, error) {
+	dialer := &net.Dialer{Timeout: requestConfig._timeout}
+	if len(requestConfig.SourceAddress) != 0 {
+		ip := net.ParseIP(requestConfig.SourceAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid sourceAddress %s", requestConfig.SourceAddress)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	if strings.HasPrefix(requestConfig.Url, "grpc://") {
+		return &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return dialer.Dial(network, addr)
+			},
+		}, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if requestConfig.TLS != nil {
+		var err error
+		if tlsConfig, err = requestConfig.TLS.TLSConfig(); err != nil {
+			return nil, err
+		}
+	}
+	tlsConfig.NextProtos = []string{http2.NextProtoTLS}
+
+	return &http2.Transport{
+		TLSClientConfig: tlsConfig,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return tls.DialWithDialer(dialer, network, addr, cfg)
+		},
+	}, nil
+}
+
+// Encodes a grpc.health.v1.HealthCheckRequest as length prefixed message
+func grpcHealthCheckRequest(service string) []byte {
+	var message []byte
+	if len(service) != 0 {
+		// field 1, wire type 2 (length delimited)
+		message = append(message, 0x0a)
+		message = appendVarint(message, uint64(len(service)))
+		message = append(message, service...)
+	}
+
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// Decodes the status of a length prefixed grpc.health.v1.HealthCheckResponse
+func parseGrpcHealthCheckResponse(body []byte) (int, error) {
+	if len(body) < 5 {
+		return 0, errors.New("Response contains no message")
+	}
+	if body[0] != 0 {
+		return 0, errors.New("Compressed responses are not supported")
+	}
+	length := binary.BigEndian.Uint32(body[1:5])
+	if uint32(len(body)-5) < length {
+		return 0, errors.New("Response message is truncated")
+	}
+	message := body[5 : 5+length]
+
+	status := GrpcUnknown
+	for len(message) != 0 {
+		key, n := binary.Uvarint(message)
+		if n <= 0 {
+			return 0, errors.New("Response message is invalid")
+		}
+		message = message[n:]
+
+		switch key & 7 {
+		case 0:
+			value, n := binary.Uvarint(message)
+			if n <= 0 {
+				return 0, errors.New("Response message is invalid")
+			}
+			message = message[n:]
+			if key>>3 == 1 {
+				status = int(value)
+			}
+		case 1:
+			if len(message) < 8 {
+				return 0, errors.New("Response message is invalid")
+			}
+			message = message[8:]
+		case 2:
+			size, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < size {
+				return 0, errors.New("Response message is invalid")
+			}
+			message = message[n+int(size):]
+		case 5:
+			if len(message) < 4 {
+				return 0, errors.New("Response message is invalid")
+			}
+			message = message[4:]
+		default:
+			return 0, errors.New("Response message is invalid")
+		}
+	}
+	return status, nil
+}
+
+// grpc-timeout header value of a duration in milliseconds
+func grpcTimeout(d time.Duration) string {
+	return strconv.FormatInt(d.Milliseconds(), 10) + "m"
+}
+
+// performs a grpc.health.v1.Health/Check call. The check fails unless the service is SERVING.
+// The serving status is saved as response code.
+func performGrpcCheck(requestConfig RequestConfig) attemptResult {
+	failure := func(err error, reason error, category string, status int, elapsed time.Duration, transient bool) attemptResult {
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:             requestConfig.Id,
+				Url:            requestConfig.Url,
+				RequestType:    requestConfig.RequestType,
+				ResponseCode:   status,
+				ResponseTimeMs: elapsed.Milliseconds(),
+				Reason:         reason,
+				Category:       category,
+				OtherInfo:      err.Error(),
+			},
+			err:       err,
+			transient: transient,
+			elapsed:   elapsed,
+		}
+	}
+
+	transport, ok := requestConfig._transport.(*http2.Transport)
+	if !ok {
+		var err error
+		if transport, err = newGrpcTransport(requestConfig); err != nil {
+			return failure(err, database.ErrCreateRequest, model.CategoryInvalidRequest, 0, 0, false)
+		}
+	}
+
+	target, _ := url.Parse(requestConfig.Url)
+	scheme := "https"
+	if target.Scheme == "grpc" {
+		scheme = "http"
+	}
+
+	ctx := context.Background()
+	if requestConfig._timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestConfig._timeout)
+		defer cancel()
+	}
+
+	request, err := http.NewRequest(http.MethodPost, scheme+"://"+target.Host+GrpcHealthMethod, bytes.NewReader(grpcHealthCheckRequest(requestConfig.Service)))
+	if err != nil {
+		return failure(err, database.ErrCreateRequest, model.CategoryInvalidRequest, 0, 0, false)
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set(ContentType, GrpcContentType)
+	request.Header.Set("TE", "trailers")
+	request.Header.Set(UserAgent, DefaultUserAgent)
+	if requestConfig._timeout > 0 {
+		request.Header.Set("grpc-timeout", grpcTimeout(requestConfig._timeout))
+	}
+	// metadata of the call
+	AddHeaders(request, requestConfig.Headers)
+
+	start := time.Now()
+	response, err := transport.RoundTrip(request)
+	if err != nil {
+		elapsed := time.Since(start)
+		category := classifyError(err)
+		reason := database.ErrDoRequest
+		if category == model.CategoryTimeout {
+			reason = database.ErrTimeout
+		}
+		return failure(err, reason, category, 0, elapsed, true)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, 1<<20))
+	elapsed := time.Since(start)
+	if err != nil {
+		category := model.CategoryBodyRead
+		if classifyError(err) == model.CategoryTimeout {
+			category = model.CategoryTimeout
+		}
+		return failure(err, database.ErrReadResponse, category, 0, elapsed, true)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		err := fmt.Errorf("Got HTTP response code %d", response.StatusCode)
+		return failure(err, err, model.CategoryUnexpectedStatus, 0, elapsed, response.StatusCode >= http.StatusInternalServerError)
+	}
+
+	// the status is sent in the trailers, or in the headers if there is no message
+	grpcStatus := response.Trailer.Get("grpc-status")
+	grpcMessage := response.Trailer.Get("grpc-message")
+	if len(grpcStatus) == 0 {
+		grpcStatus = response.Header.Get("grpc-status")
+		grpcMessage = response.Header.Get("grpc-message")
+	}
+	if grpcStatus != "0" {
+		err := fmt.Errorf("Got grpc status %s %s", grpcStatus, grpcMessage)
+		// UNAVAILABLE might not occur again
+		return failure(err, err, model.CategoryUnexpectedStatus, 0, elapsed, grpcStatus == "14")
+	}
+
+	status, err := parseGrpcHealthCheckResponse(body)
+	if err != nil {
+		return failure(err, database.ErrReadResponse, model.CategoryBodyRead, 0, elapsed, false)
+	}
+	if status != GrpcServing {
+		err := fmt.Errorf("Got health status %s. Expected SERVING", grpcStatusName(status))
+		return failure(err, err, model.CategoryUnexpectedStatus, status, elapsed, false)
+	}
+
+	return attemptResult{
+		requestInfo: model.RequestInfo{
+			Id:                   requestConfig.Id,
+			Url:                  requestConfig.Url,
+			RequestType:          requestConfig.RequestType,
+			ResponseCode:         status,
+			ResponseTimeMs:       elapsed.Milliseconds(),
+			ExpectedResponseTime: requestConfig.ResponseTime,
+		},
+		elapsed: elapsed,
+		headers: getResponseHeaders(response),
+	}
+}
+
+func grpcStatusName(status int) string {
+	if name, ok := grpcStatusNames[status]; ok {
+		return name
+	}
+	return strconv.Itoa(status)
+}
@@ -0,0 +1,146 @@
+package requests
+
+import (
+	"encoding/binary"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"statusok/model"
+	"statusok/tlsutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// grpc health service answering with the status of the requested service
+func grpcHealthHandler(statuses map[string]int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		service := ""
+		if len(body) > 7 {
+			service = string(body[7:])
+		}
+
+		if r.URL.Path != GrpcHealthMethod || r.Header.Get(ContentType) != GrpcContentType || r.Header.Get("x-team") != "backend" {
+			w.Header().Set("grpc-status", "12")
+			return
+		}
+		status, ok := statuses[service]
+		if !ok {
+			w.Header().Set("grpc-status", "5")
+			w.Header().Set("grpc-message", "unknown service")
+			return
+		}
+
+		w.Header().Set(ContentType, GrpcContentType)
+		w.Header().Set("Trailer", "grpc-status")
+		message := []byte{0x08, byte(status)}
+		frame := make([]byte, 5)
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+		w.Write(append(frame, message...))
+		w.Header().Set("grpc-status", "0")
+	})
+}
+
+func TestGrpcHealthCheckMessages(t *testing.T) {
+	assert.Equal(t, []byte{0, 0, 0, 0, 0}, grpcHealthCheckRequest(""))
+	assert.Equal(t, []byte{0, 0, 0, 0, 5, 0x0a, 3, 'a', 'p', 'i'}, grpcHealthCheckRequest("api"))
+
+	status, err := parseGrpcHealthCheckResponse([]byte{0, 0, 0, 0, 2, 0x08, 2})
+	assert.Nil(t, err)
+	assert.Equal(t, GrpcNotServing, status)
+
+	// unknown fields are skipped, a missing status is UNKNOWN
+	status, err = parseGrpcHealthCheckResponse([]byte{0, 0, 0, 0, 3, 0x12, 1, 'x'})
+	assert.Nil(t, err)
+	assert.Equal(t, GrpcUnknown, status)
+
+	_, err = parseGrpcHealthCheckResponse([]byte{0, 0, 0, 0, 3, 0x08})
+	assert.Error(t, err)
+}
+
+func TestValidateGrpc(t *testing.T) {
+	requestConfig := RequestConfig{Type: TypeGrpc, Url: "grpc://backend:50051", ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	assert.Equal(t, "GRPC", requestConfig.RequestType)
+
+	for _, invalid := range []RequestConfig{
+		{Type: TypeGrpc, Url: "http://backend:50051", ResponseTime: 100},
+		{Type: TypeGrpc, Url: "grpc://", ResponseTime: 100},
+		{Type: TypeGrpc, Url: "grpc://backend:50051", ResponseTime: 100, Proxy: "http://proxy:3128"},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func TestGrpcDialTimeout(t *testing.T) {
+	// the listener never completes the TLS handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	requestConfig := RequestConfig{Type: TypeGrpc, Url: "grpcs://" + listener.Addr().String(), Timeout: "200ms", ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	transport := requestConfig._transport.(*http2.Transport)
+
+	start := time.Now()
+	_, err = transport.DialTLS("tcp", listener.Addr().String(), transport.TLSClientConfig)
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 2*time.Second)
+}
+
+func TestGrpcCheck(t *testing.T) {
+	handler := grpcHealthHandler(map[string]int{"": GrpcServing, "billing": GrpcNotServing})
+	server := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer server.Close()
+	url := "grpc://" + strings.TrimPrefix(server.URL, "http://")
+	metadata := map[string]string{"x-team": "backend"}
+
+	serving := RequestConfig{Id: 1, Type: TypeGrpc, Url: url, ResponseTime: 100, Headers: metadata}
+	assert.Nil(t, serving.Validate())
+	result := performAttempt(serving)
+	assert.Nil(t, result.err)
+	assert.Equal(t, GrpcServing, result.requestInfo.ResponseCode)
+
+	notServing := RequestConfig{Id: 1, Type: TypeGrpc, Url: url, ResponseTime: 100, Headers: metadata, Service: "billing"}
+	assert.Nil(t, notServing.Validate())
+	result = performAttempt(notServing)
+	assert.EqualError(t, result.err, "Got health status NOT_SERVING. Expected SERVING")
+	assert.Equal(t, model.CategoryUnexpectedStatus, result.errorInfo.Category)
+	assert.Equal(t, GrpcNotServing, result.errorInfo.ResponseCode)
+
+	unknown := RequestConfig{Id: 1, Type: TypeGrpc, Url: url, ResponseTime: 100, Headers: metadata, Service: "search"}
+	assert.Nil(t, unknown.Validate())
+	assert.EqualError(t, performAttempt(unknown).err, "Got grpc status 5 unknown service")
+}
+
+func TestGrpcCheckWithTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(grpcHealthHandler(map[string]int{"": GrpcServing}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	requestConfig := RequestConfig{Id: 1, Type: TypeGrpc, Url: "grpcs://" + strings.TrimPrefix(server.URL, "https://"), ResponseTime: 100, Headers: map[string]string{"x-team": "backend"}}
+	assert.Nil(t, requestConfig.Validate())
+	assert.Equal(t, model.CategoryCertificateInvalid, performAttempt(requestConfig).errorInfo.Category)
+
+	dir, err := ioutil.TempDir("", "grpc")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.Nil(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	requestConfig.TLS = &tlsutil.Config{CaFile: caFile}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Equal(t, GrpcServing, result.requestInfo.ResponseCode)
+}
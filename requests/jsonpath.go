@@ -0,0 +1,104 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse a JSONPath expression like $.data.items[0].id or $['access_token']
+// into the names and indices to follow
+func parseJsonPath(path string) ([]interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath %s has to start with $", path)
+	}
+
+	var segments []interface{}
+	rest := path[1:]
+	for len(rest) != 0 {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if len(name) == 0 {
+				return nil, fmt.Errorf("JSONPath %s has an empty name", path)
+			}
+			segments = append(segments, name)
+			rest = rest[end+1:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("JSONPath %s has an unclosed [", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			if quoted := len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]; quoted {
+				segments = append(segments, inner[1:len(inner)-1])
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("JSONPath %s has an invalid index %s", path, inner)
+				}
+				segments = append(segments, index)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("JSONPath %s is invalid at %s", path, rest)
+		}
+	}
+	return segments, nil
+}
+
+// Returns the value at path in the JSON document as string.
+// Strings are returned as they are, other values as JSON.
+func evalJsonPath(document []byte, path string) (string, error) {
+	segments, err := parseJsonPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("Response is not valid JSON: %s", err)
+	}
+
+	for _, segment := range segments {
+		switch key := segment.(type) {
+		case string:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("%s not found, no object at %s", path, key)
+			}
+			if value, ok = object[key]; !ok {
+				return "", fmt.Errorf("%s not found, missing %s", path, key)
+			}
+		case int:
+			array, ok := value.([]interface{})
+			if !ok {
+				return "", fmt.Errorf("%s not found, no array at [%d]", path, key)
+			}
+			if key < 0 {
+				key += len(array)
+			}
+			if key < 0 || key >= len(array) {
+				return "", fmt.Errorf("%s not found, index [%d] out of range", path, key)
+			}
+			value = array[key]
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,235 @@
+package requests
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// check the url of a smtp, imap or pop3 check. The scheme is the type, followed by s for implicit TLS
+// e.g. smtp://host:587 or imaps://host
+func (requestConfig *RequestConfig) validateMail() error {
+	target, err := url.Parse(requestConfig.Url)
+	if err != nil || len(target.Host) == 0 {
+		return errors.New("Invalid Url")
+	}
+	if target.Scheme != requestConfig.Type && target.Scheme != requestConfig.Type+"s" {
+		return fmt.Errorf("Invalid Url %s, use %s://host:port or %ss://host:port", requestConfig.Url, requestConfig.Type, requestConfig.Type)
+	}
+	implicitTLS := target.Scheme == requestConfig.Type+"s"
+	if requestConfig.StartTLS && implicitTLS {
+		return fmt.Errorf("StartTLS cannot be used with %s:// urls, they use TLS from the start", target.Scheme)
+	}
+	if len(requestConfig.Proxy) != 0 {
+		return fmt.Errorf("Proxy cannot be given for a check of type %s", requestConfig.Type)
+	}
+	if requestConfig.Auth != nil {
+		if requestConfig.Auth.Type != AuthBasic {
+			return fmt.Errorf("Only basic auth can be used for a check of type %s", requestConfig.Type)
+		}
+		// never send the password in plain text
+		if !requestConfig.StartTLS && !implicitTLS {
+			return fmt.Errorf("Auth requires startTls or a %ss:// url", requestConfig.Type)
+		}
+	}
+	if len(requestConfig.RequestType) == 0 {
+		requestConfig.RequestType = strings.ToUpper(requestConfig.Type)
+	}
+	return nil
+}
+
+// mailSession is the connection of a smtp, imap or pop3 check
+type mailSession struct {
+	*checkSession
+	text *textproto.Conn
+}
+
+// Negotiates TLS after STARTTLS, the following commands are sent encrypted
+func (session *mailSession) startTLS() error {
+	if err := session.checkSession.startTLS(); err != nil {
+		return err
+	}
+	session.text = textproto.NewConn(session.conn)
+	return nil
+}
+
+// Reads a reply with the expected code of a smtp server
+func (session *mailSession) smtpReply(expectCode int) (string, error) {
+	_, message, err := session.text.ReadResponse(expectCode)
+	var replyErr *textproto.Error
+	if errors.As(err, &replyErr) {
+		return message, &replyError{Code: replyErr.Code, Msg: replyErr.Msg}
+	}
+	return message, err
+}
+
+// Sends a command to a smtp server and reads the reply
+func (session *mailSession) smtpCommand(expectCode int, format string, args ...interface{}) (string, error) {
+	if err := session.text.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+	return session.smtpReply(expectCode)
+}
+
+func (session *mailSession) smtp(requestConfig RequestConfig) error {
+	if _, err := session.smtpReply(220); err != nil {
+		return err
+	}
+	extensions, err := session.smtpCommand(250, "EHLO localhost")
+	if err != nil {
+		return err
+	}
+
+	if requestConfig.StartTLS {
+		session.done("tls")
+		if !strings.Contains(strings.ToUpper(extensions), "STARTTLS") {
+			return &replyError{Msg: "STARTTLS is not offered by the server"}
+		}
+		if _, err := session.smtpCommand(220, "STARTTLS"); err != nil {
+			return err
+		}
+		if err := session.startTLS(); err != nil {
+			return err
+		}
+		// the extensions may differ after STARTTLS
+		if _, err := session.smtpCommand(250, "EHLO localhost"); err != nil {
+			return err
+		}
+	}
+
+	if requestConfig.Auth != nil {
+		session.done("auth")
+		credentials := base64.StdEncoding.EncodeToString([]byte("\x00" + requestConfig.Auth.Username + "\x00" + requestConfig.Auth.Password))
+		if _, err := session.smtpCommand(235, "AUTH PLAIN %s", credentials); err != nil {
+			return err
+		}
+	}
+	session.done("")
+
+	session.smtpCommand(221, "QUIT")
+	return nil
+}
+
+// Sends a tagged command to an imap server and reads until the tagged reply
+func (session *mailSession) imapCommand(tag string, format string, args ...interface{}) error {
+	if err := session.text.PrintfLine(tag+" "+format, args...); err != nil {
+		return err
+	}
+	for {
+		line, err := session.text.ReadLine()
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(line, tag+" ") {
+			if !strings.HasPrefix(strings.ToUpper(line[len(tag)+1:]), "OK") {
+				return &replyError{Msg: "Got reply " + line}
+			}
+			return nil
+		}
+	}
+}
+
+// Quotes a string for an imap command
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func (session *mailSession) imap(requestConfig RequestConfig) error {
+	greeting, err := session.text.ReadLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(strings.ToUpper(greeting), "* OK") && !strings.HasPrefix(strings.ToUpper(greeting), "* PREAUTH") {
+		return &replyError{Msg: "Got reply " + greeting}
+	}
+
+	if requestConfig.StartTLS {
+		session.done("tls")
+		if err := session.imapCommand("a1", "STARTTLS"); err != nil {
+			return err
+		}
+		if err := session.startTLS(); err != nil {
+			return err
+		}
+	}
+
+	if requestConfig.Auth != nil {
+		session.done("auth")
+		if err := session.imapCommand("a2", "LOGIN %s %s", imapQuote(requestConfig.Auth.Username), imapQuote(requestConfig.Auth.Password)); err != nil {
+			return err
+		}
+	}
+	session.done("")
+
+	session.imapCommand("a3", "LOGOUT")
+	return nil
+}
+
+// Sends a command to a pop3 server and reads the reply
+func (session *mailSession) pop3Command(format string, args ...interface{}) error {
+	if err := session.text.PrintfLine(format, args...); err != nil {
+		return err
+	}
+	return session.pop3Reply()
+}
+
+func (session *mailSession) pop3Reply() error {
+	line, err := session.text.ReadLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return &replyError{Msg: "Got reply " + line}
+	}
+	return nil
+}
+
+func (session *mailSession) pop3(requestConfig RequestConfig) error {
+	if err := session.pop3Reply(); err != nil {
+		return err
+	}
+
+	if requestConfig.StartTLS {
+		session.done("tls")
+		if err := session.pop3Command("STLS"); err != nil {
+			return err
+		}
+		if err := session.startTLS(); err != nil {
+			return err
+		}
+	}
+
+	if requestConfig.Auth != nil {
+		session.done("auth")
+		if err := session.pop3Command("USER %s", requestConfig.Auth.Username); err != nil {
+			return err
+		}
+		if err := session.pop3Command("PASS %s", requestConfig.Auth.Password); err != nil {
+			return err
+		}
+	}
+	session.done("")
+
+	session.pop3Command("QUIT")
+	return nil
+}
+
+// connects to a smtp, imap or pop3 server, checks the greeting, negotiates TLS and authenticates
+// if configured. The time of each stage is saved as metric connectMs, tlsMs, greetingMs and authMs.
+func performMailCheck(requestConfig RequestConfig) attemptResult {
+	return performSessionCheck(requestConfig, func(session *checkSession) error {
+		mail := &mailSession{checkSession: session, text: textproto.NewConn(session.conn)}
+		session.done("greeting")
+		switch requestConfig.Type {
+		case TypeSmtp:
+			return mail.smtp(requestConfig)
+		case TypeImap:
+			return mail.imap(requestConfig)
+		default:
+			return mail.pop3(requestConfig)
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package requests
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"statusok/model"
+	"statusok/tlsutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeSmtp(conn net.Conn, tlsConfig *tls.Config) {
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 mail.example.com ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "EHLO "):
+			text.PrintfLine("250-mail.example.com")
+			text.PrintfLine("250-STARTTLS")
+			text.PrintfLine("250 AUTH PLAIN LOGIN")
+		case line == "STARTTLS":
+			text.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			text = textproto.NewConn(tlsConn)
+		case line == "AUTH PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00monitor\x00secret")):
+			text.PrintfLine("235 2.7.0 Authentication successful")
+		case strings.HasPrefix(line, "AUTH "):
+			text.PrintfLine("535 5.7.8 Authentication credentials invalid")
+		case line == "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func TestValidateMail(t *testing.T) {
+	requestConfig := RequestConfig{Type: TypeSmtp, Url: "smtp://mail.example.com:587", StartTLS: true, Auth: &AuthConfig{Type: AuthBasic, Username: "monitor"}, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	assert.Equal(t, "SMTP", requestConfig.RequestType)
+
+	for _, valid := range []RequestConfig{
+		{Type: TypeImap, Url: "imaps://mail.example.com", Auth: &AuthConfig{Type: AuthBasic, Username: "monitor"}, ResponseTime: 100},
+		{Type: TypePop3, Url: "pop3://mail.example.com", ResponseTime: 100},
+	} {
+		assert.Nil(t, valid.Validate())
+	}
+
+	for _, invalid := range []RequestConfig{
+		{Type: TypeSmtp, Url: "imap://mail.example.com", ResponseTime: 100},
+		{Type: TypeSmtp, Url: "smtps://mail.example.com", StartTLS: true, ResponseTime: 100},
+		{Type: TypeImap, Url: "imap://mail.example.com", Auth: &AuthConfig{Type: AuthBasic, Username: "monitor"}, ResponseTime: 100},
+		{Type: TypePop3, Url: "pop3s://mail.example.com", Auth: &AuthConfig{Type: AuthDigest, Username: "monitor"}, ResponseTime: 100},
+		{Type: TypeSmtp, Url: "smtp://mail.example.com", CertExpiryDays: -1, ResponseTime: 100},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func TestSmtpCheck(t *testing.T) {
+	address, caFile, closeServer := sessionServer(t, fakeSmtp)
+	defer closeServer()
+
+	plain := RequestConfig{Id: 1, Type: TypeSmtp, Url: "smtp://" + address, ResponseTime: 100}
+	assert.Nil(t, plain.Validate())
+	result := performAttempt(plain)
+	assert.Nil(t, result.err)
+	assert.Contains(t, result.requestInfo.Metrics, "connectMs")
+	assert.Contains(t, result.requestInfo.Metrics, "greetingMs")
+	assert.NotContains(t, result.requestInfo.Metrics, "certExpiresInDays")
+
+	auth := &AuthConfig{Type: AuthBasic, Username: "monitor", Password: "secret"}
+	requestConfig := RequestConfig{Id: 1, Type: TypeSmtp, Url: "smtp://" + address, StartTLS: true, Auth: auth, TLS: &tlsutil.Config{CaFile: caFile}, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	for _, metric := range []string{"connectMs", "greetingMs", "tlsMs", "authMs", "certExpiresInDays"} {
+		assert.Contains(t, result.requestInfo.Metrics, metric)
+	}
+
+	requestConfig.Auth = &AuthConfig{Type: AuthBasic, Username: "monitor", Password: "wrong"}
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Stage auth failed: Got reply 535 5.7.8 Authentication credentials invalid")
+	assert.Equal(t, model.CategoryAuthFailed, result.errorInfo.Category)
+	assert.Equal(t, 535, result.errorInfo.ResponseCode)
+
+	// the test certificate is valid for decades
+	requestConfig.Auth = auth
+	requestConfig.CertExpiryDays = 100000
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.Error(t, result.err)
+	assert.Equal(t, model.CategoryCertificateExpiry, result.errorInfo.Category)
+	assert.Contains(t, result.errorInfo.Metrics, "certExpiresInDays")
+}
+
+func TestSmtpCheckUntrustedCertificate(t *testing.T) {
+	address, _, closeServer := sessionServer(t, fakeSmtp)
+	defer closeServer()
+
+	requestConfig := RequestConfig{Id: 1, Type: TypeSmtp, Url: "smtp://" + address, StartTLS: true, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Equal(t, model.CategoryCertificateInvalid, result.errorInfo.Category)
+	assert.True(t, strings.HasPrefix(result.err.Error(), "Stage tls failed: "))
+}
+
+func TestImapCheck(t *testing.T) {
+	address, caFile, closeServer := sessionServer(t, func(conn net.Conn, tlsConfig *tls.Config) {
+		tlsConn := tls.Server(conn, tlsConfig)
+		text := textproto.NewConn(tlsConn)
+		text.PrintfLine("* OK IMAP4rev1 ready")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			fields := strings.SplitN(line, " ", 2)
+			switch {
+			case line == `a2 LOGIN "monitor" "se\"cret"`:
+				text.PrintfLine("a2 OK LOGIN completed")
+			case strings.HasPrefix(fields[1], "LOGIN "):
+				text.PrintfLine("%s NO [AUTHENTICATIONFAILED] Invalid credentials", fields[0])
+			case fields[1] == "LOGOUT":
+				text.PrintfLine("* BYE")
+				text.PrintfLine("%s OK LOGOUT completed", fields[0])
+				return
+			}
+		}
+	})
+	defer closeServer()
+
+	requestConfig := RequestConfig{Id: 1, Type: TypeImap, Url: "imaps://" + address, Auth: &AuthConfig{Type: AuthBasic, Username: "monitor", Password: `se"cret`}, TLS: &tlsutil.Config{CaFile: caFile}, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	for _, metric := range []string{"connectMs", "tlsMs", "greetingMs", "authMs", "certExpiresInDays"} {
+		assert.Contains(t, result.requestInfo.Metrics, metric)
+	}
+
+	requestConfig.Auth.Password = "wrong"
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Stage auth failed: Got reply a2 NO [AUTHENTICATIONFAILED] Invalid credentials")
+	assert.Equal(t, model.CategoryAuthFailed, result.errorInfo.Category)
+}
+
+// fake pop3 server sending the greeting
+func fakePop3(greeting string) func(conn net.Conn, tlsConfig *tls.Config) {
+	return func(conn net.Conn, tlsConfig *tls.Config) {
+		text := textproto.NewConn(conn)
+		text.PrintfLine(greeting)
+		for {
+			line, err := text.ReadLine()
+			if err != nil || line == "QUIT" {
+				text.PrintfLine("+OK Bye")
+				return
+			}
+		}
+	}
+}
+
+func TestPop3Check(t *testing.T) {
+	address, _, closeServer := sessionServer(t, fakePop3("+OK POP3 ready"))
+	defer closeServer()
+
+	requestConfig := RequestConfig{Id: 1, Type: TypePop3, Url: "pop3://" + address, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Contains(t, result.requestInfo.Metrics, "greetingMs")
+
+	busyAddress, _, closeBusyServer := sessionServer(t, fakePop3("-ERR Too many connections"))
+	defer closeBusyServer()
+
+	requestConfig.Url = "pop3://" + busyAddress
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Stage greeting failed: Got reply -ERR Too many connections")
+	assert.Equal(t, model.CategoryUnexpectedStatus, result.errorInfo.Category)
+}
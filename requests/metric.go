@@ -0,0 +1,268 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"statusok/database"
+	"statusok/model"
+	"strconv"
+	"strings"
+)
+
+// MetricConfig selects a value from a Prometheus text endpoint by metric name and labels or
+// from a JSON document by JSONPath. The check warns or fails when the value crosses the thresholds.
+type MetricConfig struct {
+	Name     string            `json:"name"`
+	Labels   map[string]string `json:"labels"`
+	JsonPath string            `json:"jsonPath"`
+	Warning  *MetricThreshold  `json:"warning"`
+	Critical *MetricThreshold  `json:"critical"`
+}
+
+// MetricThreshold is crossed when the value is above Above or below Below
+type MetricThreshold struct {
+	Above *float64 `json:"above"`
+	Below *float64 `json:"below"`
+}
+
+// Returns a description of the crossed threshold or an empty string
+func (threshold *MetricThreshold) crossed(value float64) string {
+	switch {
+	case threshold == nil:
+		return ""
+	case math.IsNaN(value) && (threshold.Above != nil || threshold.Below != nil):
+		return "is not a number"
+	case threshold.Above != nil && value > *threshold.Above:
+		return fmt.Sprintf("is above %v", *threshold.Above)
+	case threshold.Below != nil && value < *threshold.Below:
+		return fmt.Sprintf("is below %v", *threshold.Below)
+	}
+	return ""
+}
+
+// check the url and the metric of a metric check, the endpoint is fetched like a http check
+func (requestConfig *RequestConfig) validateMetric() error {
+	if len(requestConfig.Url) == 0 {
+		return errors.New("Invalid Url")
+	}
+	if _, err := url.Parse(requestConfig.Url); err != nil {
+		return errors.New("Invalid Url")
+	}
+	if len(requestConfig.RequestType) == 0 {
+		requestConfig.RequestType = "GET"
+	}
+
+	metric := requestConfig.Metric
+	if metric == nil || len(metric.Name) == 0 && len(metric.JsonPath) == 0 {
+		return errors.New("Name or jsonPath of metric cannot be empty for a check of type metric")
+	}
+	if len(metric.Name) != 0 && len(metric.JsonPath) != 0 {
+		return errors.New("Either name or jsonPath of metric can be given")
+	}
+	if len(metric.JsonPath) != 0 {
+		if len(metric.Labels) != 0 {
+			return errors.New("Labels of metric can only be given with name")
+		}
+		if _, err := parseJsonPath(metric.JsonPath); err != nil {
+			return err
+		}
+	}
+	for _, threshold := range []*MetricThreshold{metric.Warning, metric.Critical} {
+		if threshold != nil && threshold.Above != nil && threshold.Below != nil && *threshold.Below >= *threshold.Above {
+			return errors.New("Below of a metric threshold has to be less than above")
+		}
+	}
+	return nil
+}
+
+// Parses a sample line of the Prometheus text format like
+// http_requests_total{method="post",code="200"} 1027 1395066363000
+func parsePrometheusSample(line string) (string, map[string]string, float64, error) {
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return "", nil, 0, fmt.Errorf("Invalid sample %q", line)
+	}
+	name, rest := line[:end], line[end:]
+
+	labels := make(map[string]string)
+	if rest[0] == '{' {
+		rest = rest[1:]
+		for {
+			rest = strings.TrimLeft(rest, " \t,")
+			if strings.HasPrefix(rest, "}") {
+				rest = rest[1:]
+				break
+			}
+			eq := strings.Index(rest, "=\"")
+			if eq <= 0 {
+				return "", nil, 0, fmt.Errorf("Invalid labels in sample %q", line)
+			}
+			label := strings.TrimSpace(rest[:eq])
+			rest = rest[eq+2:]
+
+			var value strings.Builder
+			closed := false
+			for i := 0; i < len(rest); i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+					if rest[i] == 'n' {
+						value.WriteByte('\n')
+					} else {
+						value.WriteByte(rest[i])
+					}
+				} else if rest[i] == '"' {
+					rest, closed = rest[i+1:], true
+					break
+				} else {
+					value.WriteByte(rest[i])
+				}
+			}
+			if !closed {
+				return "", nil, 0, fmt.Errorf("Unclosed label value in sample %q", line)
+			}
+			labels[label] = value.String()
+		}
+	}
+
+	// value optionally followed by a timestamp
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, 0, fmt.Errorf("Missing value in sample %q", line)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("Invalid value in sample %q", line)
+	}
+	return name, labels, value, nil
+}
+
+// Returns the value of the only sample with the name and at least the given labels
+func findPrometheusSample(body string, name string, labels map[string]string) (float64, error) {
+	var value float64
+	found := 0
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' || !strings.HasPrefix(line, name) {
+			continue
+		}
+		sampleName, sampleLabels, sampleValue, err := parsePrometheusSample(line)
+		if err != nil {
+			return 0, err
+		}
+		if sampleName != name {
+			continue
+		}
+		matches := true
+		for label, expected := range labels {
+			if sampleLabels[label] != expected {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			value = sampleValue
+			found++
+		}
+	}
+
+	switch {
+	case found == 0:
+		return 0, fmt.Errorf("Metric %s%s not found", name, formatLabels(labels))
+	case found > 1:
+		return 0, fmt.Errorf("Metric %s%s matches %d samples, add labels to select one", name, formatLabels(labels), found)
+	}
+	return value, nil
+}
+
+// Formats labels like {code="200",method="post"}
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var pairs []string
+	for _, label := range sortedKeys(labels) {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", label, labels[label]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Returns the number at the JSONPath of the document, booleans count as 1 and 0
+func findJsonMetric(body []byte, path string) (float64, error) {
+	result, err := evalJsonPath(body, path)
+	if err != nil {
+		return 0, err
+	}
+	switch result {
+	case "true":
+		return 1, nil
+	case "false":
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(result, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Value %q at %s is not a number", result, path)
+	}
+	return value, nil
+}
+
+// fetches the endpoint like a http check and compares the selected value to the thresholds.
+// The value is saved as metric value, crossing the warning or critical threshold fails the check.
+func performMetricCheck(requestConfig RequestConfig) attemptResult {
+	result := performHttpRequest(requestConfig)
+	if result.err != nil {
+		return result
+	}
+
+	metric := requestConfig.Metric
+	var value float64
+	var err error
+	selector := metric.JsonPath
+	if len(metric.JsonPath) != 0 {
+		value, err = findJsonMetric(result.body, metric.JsonPath)
+	} else {
+		selector = metric.Name + formatLabels(metric.Labels)
+		value, err = findPrometheusSample(string(result.body), metric.Name, metric.Labels)
+	}
+
+	metrics := result.requestInfo.Metrics
+	if metrics == nil {
+		metrics = make(map[string]float64)
+	}
+	failure := func(err error, reason error, category string) attemptResult {
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:             requestConfig.Id,
+				Url:            requestConfig.Url,
+				RequestType:    requestConfig.RequestType,
+				ResponseCode:   result.requestInfo.ResponseCode,
+				ResponseTimeMs: result.requestInfo.ResponseTimeMs,
+				Headers:        result.headers,
+				Reason:         reason,
+				Category:       category,
+				OtherInfo:      err.Error(),
+				Metrics:        metrics,
+			},
+			err:     err,
+			elapsed: result.elapsed,
+		}
+	}
+	if err != nil {
+		return failure(err, database.ErrCheckUnknown, model.CategoryCheckUnknown)
+	}
+
+	// NaN and infinite values cannot be saved as fields
+	if !math.IsNaN(value) && !math.IsInf(value, 0) {
+		metrics["value"] = value
+	}
+	if crossed := metric.Critical.crossed(value); len(crossed) != 0 {
+		return failure(fmt.Errorf("%s %v %s", selector, value, crossed), database.ErrCheckCritical, model.CategoryCheckCritical)
+	}
+	if crossed := metric.Warning.crossed(value); len(crossed) != 0 {
+		return failure(fmt.Errorf("%s %v %s", selector, value, crossed), database.ErrCheckWarning, model.CategoryCheckWarning)
+	}
+
+	result.requestInfo.Metrics = metrics
+	return result
+}
@@ -0,0 +1,119 @@
+package requests
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"statusok/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const prometheusMetrics = `# HELP queue_depth Messages waiting in the queue.
+# TYPE queue_depth gauge
+queue_depth{queue="orders"} 1250
+queue_depth{queue="mails",priority="high"} 3 1395066363000
+queue_depth_max 10000
+# HELP replication_lag_seconds Lag of the replica.
+replication_lag_seconds{replica="db-2",note="a \"quoted\", value"} 0.5
+up NaN
+`
+
+func TestParsePrometheusSample(t *testing.T) {
+	name, labels, value, err := parsePrometheusSample(`replication_lag_seconds{replica="db-2",note="a \"quoted\", value"} 0.5`)
+	assert.Nil(t, err)
+	assert.Equal(t, "replication_lag_seconds", name)
+	assert.Equal(t, map[string]string{"replica": "db-2", "note": `a "quoted", value`}, labels)
+	assert.Equal(t, 0.5, value)
+
+	_, _, value, err = parsePrometheusSample("up +Inf")
+	assert.Nil(t, err)
+	assert.True(t, math.IsInf(value, 1))
+
+	for _, invalid := range []string{`up{job="api} 1`, "up", "up one", `{job="api"} 1`} {
+		_, _, _, err := parsePrometheusSample(invalid)
+		assert.Error(t, err)
+	}
+}
+
+func TestFindPrometheusSample(t *testing.T) {
+	value, err := findPrometheusSample(prometheusMetrics, "queue_depth", map[string]string{"queue": "orders"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1250.0, value)
+
+	value, err = findPrometheusSample(prometheusMetrics, "queue_depth_max", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 10000.0, value)
+
+	_, err = findPrometheusSample(prometheusMetrics, "queue_depth", nil)
+	assert.EqualError(t, err, "Metric queue_depth matches 2 samples, add labels to select one")
+
+	_, err = findPrometheusSample(prometheusMetrics, "queue_depth", map[string]string{"queue": "invoices"})
+	assert.EqualError(t, err, `Metric queue_depth{queue="invoices"} not found`)
+}
+
+func TestValidateMetric(t *testing.T) {
+	limit := 100.0
+	requestConfig := RequestConfig{Type: TypeMetric, Url: "http://app:9090/metrics", Metric: &MetricConfig{Name: "up"}, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	assert.Equal(t, "GET", requestConfig.RequestType)
+
+	for _, invalid := range []RequestConfig{
+		{Type: TypeMetric, Url: "http://app/metrics", ResponseTime: 100},
+		{Type: TypeMetric, Url: "http://app/metrics", Metric: &MetricConfig{Name: "up", JsonPath: "$.up"}, ResponseTime: 100},
+		{Type: TypeMetric, Url: "http://app/health", Metric: &MetricConfig{JsonPath: "lag"}, ResponseTime: 100},
+		{Type: TypeMetric, Url: "http://app/health", Metric: &MetricConfig{JsonPath: "$.lag", Labels: map[string]string{"db": "main"}}, ResponseTime: 100},
+		{Type: TypeMetric, Url: "http://app/health", Metric: &MetricConfig{JsonPath: "$.lag", Warning: &MetricThreshold{Above: &limit, Below: &limit}}, ResponseTime: 100},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func TestMetricCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.Write([]byte(`{"database":{"replicationLag":4.5,"healthy":true}}`))
+			return
+		}
+		w.Write([]byte(prometheusMetrics))
+	}))
+	defer server.Close()
+
+	warning, critical := 1000.0, 5000.0
+	requestConfig := RequestConfig{Id: 1, Type: TypeMetric, Url: server.URL + "/metrics", ResponseTime: 100,
+		Metric: &MetricConfig{Name: "queue_depth", Labels: map[string]string{"queue": "orders"}, Critical: &MetricThreshold{Above: &critical}}}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Equal(t, 1250.0, result.requestInfo.Metrics["value"])
+
+	requestConfig.Metric.Warning = &MetricThreshold{Above: &warning}
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, `queue_depth{queue="orders"} 1250 is above 1000`)
+	assert.Equal(t, model.CategoryCheckWarning, result.errorInfo.Category)
+	assert.Equal(t, 1250.0, result.errorInfo.Metrics["value"])
+
+	critical = 1000
+	result = performAttempt(requestConfig)
+	assert.Equal(t, model.CategoryCheckCritical, result.errorInfo.Category)
+
+	requestConfig.Metric = &MetricConfig{Name: "up", Critical: &MetricThreshold{Below: &critical}}
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "up NaN is not a number")
+	assert.NotContains(t, result.errorInfo.Metrics, "value")
+
+	requestConfig.Metric = &MetricConfig{Name: "queue_length"}
+	result = performAttempt(requestConfig)
+	assert.Equal(t, model.CategoryCheckUnknown, result.errorInfo.Category)
+
+	lag := 2.0
+	requestConfig.Url = server.URL + "/health"
+	requestConfig.Metric = &MetricConfig{JsonPath: "$.database.replicationLag", Warning: &MetricThreshold{Above: &lag}}
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "$.database.replicationLag 4.5 is above 2")
+
+	requestConfig.Metric = &MetricConfig{JsonPath: "$.database.healthy", Critical: &MetricThreshold{Below: &lag}}
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "$.database.healthy 1 is below 2")
+}
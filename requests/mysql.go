@@ -0,0 +1,438 @@
+package requests
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+const maxMysqlPacket = 1 << 24
+
+// Capability flags of the mysql client
+const (
+	mysqlClientLongPassword     = 0x00000001
+	mysqlClientConnectWithDb    = 0x00000008
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+	mysqlClientPluginAuth       = 0x00080000
+)
+
+const (
+	mysqlNativePassword  = "mysql_native_password"
+	mysqlCachingSha2     = "caching_sha2_password"
+	mysqlCharsetUtf8mb4  = 45
+	mysqlComQuit         = 0x01
+	mysqlComQuery        = 0x03
+	mysqlComPing         = 0x0e
+	mysqlFastAuthSuccess = 3
+	mysqlFullAuth        = 4
+	mysqlRequestKey      = 2
+)
+
+// mysqlConn reads and writes the packets of the mysql protocol with their sequence ids
+type mysqlConn struct {
+	conn     net.Conn
+	sequence byte
+}
+
+func (c *mysqlConn) writePacket(payload []byte) error {
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), c.sequence}
+	c.sequence++
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
+
+func (c *mysqlConn) readPacket() ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if length >= maxMysqlPacket-1 {
+		return nil, errors.New("Packets larger than 16MB are not supported")
+	}
+	c.sequence = header[3] + 1
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.conn, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// Sends a command, every command starts a new sequence
+func (c *mysqlConn) command(command byte, data []byte) error {
+	c.sequence = 0
+	return c.writePacket(append([]byte{command}, data...))
+}
+
+// Message of an error packet e.g. 1045 Access denied for user 'monitor'
+func mysqlError(packet []byte) *replyError {
+	if len(packet) < 3 {
+		return &replyError{Msg: "Got error reply"}
+	}
+	code := int(binary.LittleEndian.Uint16(packet[1:3]))
+	message := packet[3:]
+	if len(message) >= 6 && message[0] == '#' {
+		// sql state
+		message = message[6:]
+	}
+	return &replyError{Code: code, Msg: string(message)}
+}
+
+func isMysqlEOF(packet []byte) bool {
+	return len(packet) < 9 && len(packet) != 0 && packet[0] == 0xfe
+}
+
+// Reads a length encoded integer, the second result is false for NULL
+func readLengthEncoded(data []byte) (uint64, int, bool) {
+	if len(data) == 0 {
+		return 0, 0, true
+	}
+	switch data[0] {
+	case 0xfb:
+		return 0, 1, false
+	case 0xfc:
+		if len(data) < 3 {
+			return 0, len(data), true
+		}
+		return uint64(binary.LittleEndian.Uint16(data[1:])), 3, true
+	case 0xfd:
+		if len(data) < 4 {
+			return 0, len(data), true
+		}
+		return uint64(data[1]) | uint64(data[2])<<8 | uint64(data[3])<<16, 4, true
+	case 0xfe:
+		if len(data) < 9 {
+			return 0, len(data), true
+		}
+		return binary.LittleEndian.Uint64(data[1:]), 9, true
+	default:
+		return uint64(data[0]), 1, true
+	}
+}
+
+// mysqlHandshake is the initial handshake packet of the server
+type mysqlHandshake struct {
+	capabilities uint32
+	scramble     []byte
+	plugin       string
+}
+
+func parseMysqlHandshake(packet []byte) (*mysqlHandshake, error) {
+	if len(packet) != 0 && packet[0] == 0xff {
+		return nil, mysqlError(packet)
+	}
+	if len(packet) == 0 || packet[0] != 10 {
+		return nil, errors.New("Unsupported protocol version of the server")
+	}
+	invalid := errors.New("Handshake of the server is invalid")
+
+	// server version
+	end := bytes.IndexByte(packet[1:], 0)
+	if end == -1 {
+		return nil, invalid
+	}
+	rest := packet[end+2:]
+	// connection id, scramble part 1, filler and capabilities
+	if len(rest) < 4+8+1+2 {
+		return nil, invalid
+	}
+	handshake := &mysqlHandshake{scramble: append([]byte{}, rest[4:12]...)}
+	handshake.capabilities = uint32(binary.LittleEndian.Uint16(rest[13:15]))
+	rest = rest[15:]
+
+	// character set, status, upper capabilities, scramble length and reserved bytes
+	if len(rest) >= 16 {
+		handshake.capabilities |= uint32(binary.LittleEndian.Uint16(rest[3:5])) << 16
+		scrambleLength := int(rest[5])
+		rest = rest[16:]
+		part2 := 13
+		if scrambleLength-8 > part2 {
+			part2 = scrambleLength - 8
+		}
+		if len(rest) < part2 {
+			return nil, invalid
+		}
+		handshake.scramble = append(handshake.scramble, bytes.TrimRight(rest[:part2], "\x00")...)
+		rest = rest[part2:]
+		if end := bytes.IndexByte(rest, 0); end != -1 {
+			handshake.plugin = string(rest[:end])
+		} else {
+			handshake.plugin = string(rest)
+		}
+	}
+	if len(handshake.plugin) == 0 {
+		handshake.plugin = mysqlNativePassword
+	}
+	return handshake, nil
+}
+
+// Scrambled password of an auth plugin
+func mysqlAuthResponse(plugin string, password string, scramble []byte) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, nil
+	}
+	if len(scramble) < 20 {
+		return nil, errors.New("Scramble of the server is too short")
+	}
+	switch plugin {
+	case mysqlNativePassword:
+		// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+		stage1 := sha1.Sum([]byte(password))
+		stage2 := sha1.Sum(stage1[:])
+		hash := sha1.New()
+		hash.Write(scramble[:20])
+		hash.Write(stage2[:])
+		response := hash.Sum(nil)
+		for i := range response {
+			response[i] ^= stage1[i]
+		}
+		return response, nil
+	case mysqlCachingSha2:
+		// SHA256(password) XOR SHA256(SHA256(SHA256(password)) + scramble)
+		stage1 := sha256.Sum256([]byte(password))
+		stage2 := sha256.Sum256(stage1[:])
+		hash := sha256.New()
+		hash.Write(stage2[:])
+		hash.Write(scramble[:20])
+		response := hash.Sum(nil)
+		for i := range response {
+			response[i] ^= stage1[i]
+		}
+		return response, nil
+	default:
+		return nil, fmt.Errorf("Unsupported auth plugin %s", plugin)
+	}
+}
+
+// Password encrypted with the public key of the server for caching_sha2_password without TLS
+func mysqlEncryptPassword(password string, scramble []byte, publicKey []byte) ([]byte, error) {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return nil, errors.New("Public key of the server is invalid")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("Public key of the server is not a RSA key")
+	}
+	plain := append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaKey, plain, nil)
+}
+
+// Answers the handshake of the server and authenticates, negotiating TLS if startTls is set
+func mysqlConnect(session *checkSession, requestConfig RequestConfig) (*mysqlConn, error) {
+	c := &mysqlConn{conn: session.conn}
+	packet, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	handshake, err := parseMysqlHandshake(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities := uint32(mysqlClientLongPassword | mysqlClientProtocol41 | mysqlClientSecureConnection | mysqlClientPluginAuth)
+	database := databaseName(requestConfig)
+	if len(database) != 0 {
+		capabilities |= mysqlClientConnectWithDb
+	}
+	header := make([]byte, 32)
+	header[8] = mysqlCharsetUtf8mb4
+
+	if requestConfig.StartTLS {
+		session.done("tls")
+		if handshake.capabilities&mysqlClientSSL == 0 {
+			return nil, &replyError{Msg: "TLS is not supported by the server"}
+		}
+		capabilities |= mysqlClientSSL
+		binary.LittleEndian.PutUint32(header, capabilities)
+		binary.LittleEndian.PutUint32(header[4:], maxMysqlPacket)
+		if err := c.writePacket(header); err != nil {
+			return nil, err
+		}
+		if err := session.startTLS(); err != nil {
+			return nil, err
+		}
+		c.conn = session.conn
+	}
+
+	session.done("auth")
+	password := requestConfig.Auth.Password
+	plugin := handshake.plugin
+	scramble := handshake.scramble
+	authResponse, err := mysqlAuthResponse(plugin, password, scramble)
+	if err != nil {
+		return nil, err
+	}
+
+	binary.LittleEndian.PutUint32(header, capabilities)
+	binary.LittleEndian.PutUint32(header[4:], maxMysqlPacket)
+	response := append([]byte{}, header...)
+	response = append(append(response, requestConfig.Auth.Username...), 0)
+	response = append(append(response, byte(len(authResponse))), authResponse...)
+	if len(database) != 0 {
+		response = append(append(response, database...), 0)
+	}
+	response = append(append(response, plugin...), 0)
+	if err := c.writePacket(response); err != nil {
+		return nil, err
+	}
+
+	for {
+		packet, err := c.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		if len(packet) == 0 {
+			return nil, errors.New("Got empty packet")
+		}
+
+		switch packet[0] {
+		case 0x00:
+			return c, nil
+		case 0xff:
+			return nil, mysqlError(packet)
+		case 0xfe:
+			// switch to the auth plugin requested by the server
+			end := bytes.IndexByte(packet[1:], 0)
+			if end == -1 {
+				return nil, errors.New("Auth switch request is invalid")
+			}
+			plugin = string(packet[1 : end+1])
+			scramble = bytes.TrimRight(packet[end+2:], "\x00")
+			if authResponse, err = mysqlAuthResponse(plugin, password, scramble); err != nil {
+				return nil, err
+			}
+			err = c.writePacket(authResponse)
+		case 0x01:
+			if len(packet) < 2 || plugin != mysqlCachingSha2 {
+				return nil, errors.New("Unexpected auth data of the server")
+			}
+			switch {
+			case packet[1] == mysqlFastAuthSuccess:
+				// the OK packet follows
+			case packet[1] == mysqlFullAuth && session.tlsState != nil:
+				err = c.writePacket(append([]byte(password), 0))
+			case packet[1] == mysqlFullAuth && requestConfig.PublicKeyRetrieval:
+				// the key is not verified, anyone in between can answer with their own
+				err = c.writePacket([]byte{mysqlRequestKey})
+			case packet[1] == mysqlFullAuth:
+				return nil, &replyError{Msg: "Server requested the full password without TLS, use startTls or allowPublicKeyRetrieval"}
+			default:
+				// public key of the server
+				var encrypted []byte
+				if encrypted, err = mysqlEncryptPassword(password, scramble, packet[1:]); err == nil {
+					err = c.writePacket(encrypted)
+				}
+			}
+		default:
+			return nil, errors.New("Unexpected reply to the authentication")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Runs a query and returns the first column of the first row
+func mysqlQuery(c *mysqlConn, statement string) (string, bool, error) {
+	if err := c.command(mysqlComQuery, []byte(statement)); err != nil {
+		return "", false, err
+	}
+	packet, err := c.readPacket()
+	if err != nil {
+		return "", false, err
+	}
+	switch {
+	case len(packet) == 0:
+		return "", false, errors.New("Got empty packet")
+	case packet[0] == 0xff:
+		return "", false, &queryError{Msg: mysqlError(packet).Error()}
+	case packet[0] == 0x00:
+		// no result set e.g. for an update
+		return "", true, nil
+	}
+
+	columns, _, _ := readLengthEncoded(packet)
+	for i := uint64(0); i <= columns; i++ {
+		// column definitions followed by EOF
+		if packet, err = c.readPacket(); err != nil {
+			return "", false, err
+		}
+	}
+
+	result, null, found := "", true, false
+	for {
+		packet, err := c.readPacket()
+		if err != nil {
+			return "", false, err
+		}
+		if len(packet) != 0 && packet[0] == 0xff {
+			return "", false, &queryError{Msg: mysqlError(packet).Error()}
+		}
+		if isMysqlEOF(packet) {
+			return result, null, nil
+		}
+		if found {
+			continue
+		}
+		found = true
+		length, n, ok := readLengthEncoded(packet)
+		if ok && uint64(len(packet)-n) >= length {
+			result, null = string(packet[n:n+int(length)]), false
+		}
+	}
+}
+
+// Authenticates and runs the query or a ping
+func mysqlCheck(session *checkSession, requestConfig RequestConfig) error {
+	session.done("greeting")
+	c, err := mysqlConnect(session, requestConfig)
+	if err != nil {
+		return err
+	}
+
+	session.done("query")
+	if requestConfig.Query == nil {
+		if err := c.command(mysqlComPing, nil); err != nil {
+			return err
+		}
+		packet, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+		if len(packet) == 0 || packet[0] != 0x00 {
+			return &replyError{Msg: "Got no OK reply to ping"}
+		}
+		session.done("")
+	} else {
+		result, null, err := mysqlQuery(c, requestConfig.Query.Statement)
+		if err != nil {
+			return err
+		}
+		session.done("")
+		if err := requestConfig.Query.check(result, null, session.metrics); err != nil {
+			return err
+		}
+	}
+
+	c.command(mysqlComQuit, nil)
+	return nil
+}
@@ -0,0 +1,185 @@
+package requests
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"net"
+	"statusok/model"
+	"statusok/tlsutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var mysqlScramble = []byte("0123456789abcdefghij")
+
+// fake mysql server authenticating the user monitor with password secret. With caching_sha2_password
+// the server asks for full authentication, the password is sent in clear over TLS or RSA encrypted.
+func fakeMysql(plugin string, key *rsa.PrivateKey) func(conn net.Conn, tlsConfig *tls.Config) {
+	return func(conn net.Conn, tlsConfig *tls.Config) {
+		c := &mysqlConn{conn: conn}
+		errorPacket := func(code uint16, state string, message string) []byte {
+			packet := []byte{0xff, byte(code), byte(code >> 8), '#'}
+			return append(append(packet, state...), message...)
+		}
+		ok := []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+
+		capabilities := uint32(mysqlClientProtocol41 | mysqlClientSecureConnection | mysqlClientPluginAuth | mysqlClientSSL | mysqlClientConnectWithDb)
+		handshake := append([]byte{10}, "8.0.34\x00"...)
+		handshake = append(handshake, 1, 0, 0, 0)
+		handshake = append(append(handshake, mysqlScramble[:8]...), 0)
+		handshake = append(handshake, byte(capabilities), byte(capabilities>>8), mysqlCharsetUtf8mb4, 2, 0, byte(capabilities>>16), byte(capabilities>>24), 21)
+		handshake = append(handshake, make([]byte, 10)...)
+		handshake = append(append(handshake, mysqlScramble[8:]...), 0)
+		handshake = append(append(handshake, plugin...), 0)
+		c.writePacket(handshake)
+
+		response, err := c.readPacket()
+		if err != nil {
+			return
+		}
+		if len(response) == 32 && binary.LittleEndian.Uint32(response)&mysqlClientSSL != 0 {
+			tlsConn := tls.Server(conn, tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			c.conn = tlsConn
+			if response, err = c.readPacket(); err != nil {
+				return
+			}
+		}
+
+		rest := response[32:]
+		end := bytes.IndexByte(rest, 0)
+		user := string(rest[:end])
+		rest = rest[end+1:]
+		authResponse := rest[1 : 1+int(rest[0])]
+		rest = rest[1+int(rest[0]):]
+		database := string(rest[:bytes.IndexByte(rest, 0)])
+
+		var password []byte
+		if plugin == mysqlNativePassword {
+			// verify SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+			stage1 := sha1.Sum([]byte("secret"))
+			stored := sha1.Sum(stage1[:])
+			hash := sha1.Sum(append(append([]byte{}, mysqlScramble...), stored[:]...))
+			candidate := make([]byte, len(hash))
+			for i := range hash {
+				candidate[i] = hash[i] ^ authResponse[i%len(authResponse)]
+			}
+			if check := sha1.Sum(candidate); bytes.Equal(check[:], stored[:]) {
+				password = []byte("secret")
+			}
+		} else {
+			// full authentication
+			c.writePacket([]byte{0x01, mysqlFullAuth})
+			packet, _ := c.readPacket()
+			if c.conn != conn {
+				password = bytes.TrimSuffix(packet, []byte{0})
+			} else if bytes.Equal(packet, []byte{mysqlRequestKey}) {
+				der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+				c.writePacket(append([]byte{0x01}, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...))
+				encrypted, _ := c.readPacket()
+				plain, _ := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, encrypted, nil)
+				for i := range plain {
+					plain[i] ^= mysqlScramble[i%len(mysqlScramble)]
+				}
+				password = bytes.TrimSuffix(plain, []byte{0})
+			}
+		}
+		if user != "monitor" || string(password) != "secret" || database != "orders" {
+			c.writePacket(errorPacket(1045, "28000", "Access denied for user 'monitor'@'localhost' (using password: YES)"))
+			return
+		}
+		c.writePacket(ok)
+
+		for {
+			packet, err := c.readPacket()
+			if err != nil || len(packet) == 0 {
+				return
+			}
+			switch {
+			case packet[0] == mysqlComPing:
+				c.writePacket(ok)
+			case packet[0] == mysqlComQuery && string(packet[1:]) == "SELECT @@read_only":
+				c.writePacket([]byte{1})
+				c.writePacket([]byte("\x03def\x00\x00\x00\x0a@@read_only"))
+				c.writePacket([]byte{0xfe, 0, 0, 2, 0})
+				c.writePacket([]byte{1, '0'})
+				c.writePacket([]byte{0xfe, 0, 0, 2, 0})
+			case packet[0] == mysqlComQuery:
+				c.writePacket(errorPacket(1146, "42S02", "Table 'orders.missing' doesn't exist"))
+			default:
+				return
+			}
+		}
+	}
+}
+
+func TestMysqlCheck(t *testing.T) {
+	address, caFile, closeServer := sessionServer(t, fakeMysql(mysqlNativePassword, nil))
+	defer closeServer()
+
+	auth := &AuthConfig{Type: AuthBasic, Username: "monitor", Password: "secret"}
+	requestConfig := RequestConfig{Id: 1, Type: TypeMysql, Url: "mysql://" + address + "/orders", Auth: auth, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	for _, metric := range []string{"connectMs", "greetingMs", "authMs", "queryMs"} {
+		assert.Contains(t, result.requestInfo.Metrics, metric)
+	}
+
+	requestConfig.Query = &QueryConfig{Statement: "SELECT @@read_only", Equals: "0"}
+	result = performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Equal(t, 0.0, result.requestInfo.Metrics["result"])
+
+	requestConfig.Query = &QueryConfig{Statement: "SELECT count(*) FROM missing"}
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Stage query failed: Got reply 1146 Table 'orders.missing' doesn't exist")
+	assert.Equal(t, model.CategoryQueryFailed, result.errorInfo.Category)
+
+	requestConfig.Query = nil
+	requestConfig.Auth = &AuthConfig{Type: AuthBasic, Username: "monitor", Password: "wrong"}
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Stage auth failed: Got reply 1045 Access denied for user 'monitor'@'localhost' (using password: YES)")
+	assert.Equal(t, model.CategoryAuthFailed, result.errorInfo.Category)
+	assert.Equal(t, 1045, result.errorInfo.ResponseCode)
+
+	requestConfig.Auth = auth
+	requestConfig.StartTLS = true
+	requestConfig.TLS = &tlsutil.Config{CaFile: caFile}
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Contains(t, result.requestInfo.Metrics, "tlsMs")
+}
+
+func TestMysqlCachingSha2FullAuth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	address, caFile, closeServer := sessionServer(t, fakeMysql(mysqlCachingSha2, key))
+	defer closeServer()
+
+	// without TLS the password is not sent unless retrieving the public key of the server is allowed
+	requestConfig := RequestConfig{Id: 1, Type: TypeMysql, Url: "mysql://" + address + "/orders", Auth: &AuthConfig{Type: AuthBasic, Username: "monitor", Password: "secret"}, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Stage auth failed: Server requested the full password without TLS, use startTls or allowPublicKeyRetrieval")
+	assert.Equal(t, model.CategoryAuthFailed, result.errorInfo.Category)
+
+	requestConfig.PublicKeyRetrieval = true
+	assert.Nil(t, requestConfig.Validate())
+	assert.Nil(t, performAttempt(requestConfig).err)
+
+	requestConfig.StartTLS = true
+	requestConfig.TLS = &tlsutil.Config{CaFile: caFile}
+	assert.Nil(t, requestConfig.Validate())
+	assert.Nil(t, performAttempt(requestConfig).err)
+}
@@ -0,0 +1,344 @@
+package requests
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	postgresProtocolVersion = 196608
+	postgresSSLRequest      = 80877103
+	maxPostgresMessage      = 1 << 20
+)
+
+// Authentication request codes of postgres
+const (
+	postgresAuthOk           = 0
+	postgresAuthCleartext    = 3
+	postgresAuthMD5          = 5
+	postgresAuthSASL         = 10
+	postgresAuthSASLContinue = 11
+	postgresAuthSASLFinal    = 12
+)
+
+// Writes a message with its type and length, the startup and SSL request have no type
+func writePostgresMessage(w io.Writer, messageType byte, payload []byte) error {
+	var message []byte
+	if messageType != 0 {
+		message = append(message, messageType)
+	}
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(payload)+4))
+	message = append(message, length...)
+	_, err := w.Write(append(message, payload...))
+	return err
+}
+
+// Reads a message of the server
+func readPostgresMessage(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < 4 || length > maxPostgresMessage {
+		return 0, nil, fmt.Errorf("Invalid message length %d", length)
+	}
+	payload := make([]byte, length-4)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// Message of an ErrorResponse e.g. FATAL 28P01 password authentication failed for user "monitor"
+func postgresError(payload []byte) string {
+	fields := make(map[byte]string)
+	for len(payload) > 1 {
+		end := bytes.IndexByte(payload[1:], 0)
+		if end == -1 {
+			break
+		}
+		fields[payload[0]] = string(payload[1 : end+1])
+		payload = payload[end+2:]
+	}
+	return strings.TrimSpace(fields['S'] + " " + fields['C'] + " " + fields['M'])
+}
+
+// Negotiates TLS with a SSLRequest before the startup message
+func postgresStartTLS(session *checkSession) error {
+	code := make([]byte, 4)
+	binary.BigEndian.PutUint32(code, postgresSSLRequest)
+	if err := writePostgresMessage(session.conn, 0, code); err != nil {
+		return err
+	}
+	answer := make([]byte, 1)
+	if _, err := io.ReadFull(session.conn, answer); err != nil {
+		return err
+	}
+	if answer[0] != 'S' {
+		return &replyError{Msg: "TLS is not supported by the server"}
+	}
+	return session.startTLS()
+}
+
+// scramClient is the client side of a SCRAM-SHA-256 authentication (RFC 7677)
+type scramClient struct {
+	password        string
+	clientNonce     string
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func newScramClient(password string) (*scramClient, error) {
+	nonce := make([]byte, 18)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	client := &scramClient{password: password, clientNonce: base64.StdEncoding.EncodeToString(nonce)}
+	// the user name is taken from the startup message
+	client.clientFirstBare = "n=,r=" + client.clientNonce
+	return client, nil
+}
+
+func (client *scramClient) clientFirst() string {
+	return "n,," + client.clientFirstBare
+}
+
+// Answers the server first message with the client proof
+func (client *scramClient) clientFinal(serverFirst string) (string, error) {
+	params := make(map[string]string)
+	for _, attribute := range strings.Split(serverFirst, ",") {
+		if len(attribute) > 2 && attribute[1] == '=' {
+			params[attribute[:1]] = attribute[2:]
+		}
+	}
+	nonce := params["r"]
+	if !strings.HasPrefix(nonce, client.clientNonce) {
+		return "", errors.New("SCRAM nonce of the server is invalid")
+	}
+	salt, err := base64.StdEncoding.DecodeString(params["s"])
+	if err != nil {
+		return "", errors.New("SCRAM salt of the server is invalid")
+	}
+	iterations, err := strconv.Atoi(params["i"])
+	if err != nil || iterations < 1 {
+		return "", errors.New("SCRAM iteration count of the server is invalid")
+	}
+
+	saltedPassword := scramSaltedPassword(client.password, salt, iterations)
+	clientKey := hmacSha256(saltedPassword, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	withoutProof := "c=biws,r=" + nonce
+	authMessage := []byte(client.clientFirstBare + "," + serverFirst + "," + withoutProof)
+	clientSignature := hmacSha256(storedKey[:], authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+	client.serverSignature = hmacSha256(hmacSha256(saltedPassword, []byte("Server Key")), authMessage)
+
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// Verifies the signature in the server final message
+func (client *scramClient) verify(serverFinal string) error {
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(serverFinal, "v="))
+	if err != nil || !strings.HasPrefix(serverFinal, "v=") || !hmac.Equal(signature, client.serverSignature) {
+		return errors.New("SCRAM signature of the server is invalid")
+	}
+	return nil
+}
+
+// PBKDF2 with HMAC-SHA-256 for a single block, the Hi function of SCRAM
+func scramSaltedPassword(password string, salt []byte, iterations int) []byte {
+	u := hmacSha256([]byte(password), append(append([]byte{}, salt...), 0, 0, 0, 1))
+	result := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		u = hmacSha256([]byte(password), u)
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+func hmacSha256(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// Password message of md5 authentication
+func postgresMD5Password(username, password string, salt []byte) string {
+	inner := md5.Sum([]byte(password + username))
+	outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+	return "md5" + hex.EncodeToString(outer[:])
+}
+
+// Sends the startup message and authenticates until the server is ready for queries.
+// Without TLS only SCRAM is answered, a cleartext or md5 password could be read by anyone in between.
+func postgresStartup(conn net.Conn, requestConfig RequestConfig, encrypted bool) error {
+	username := requestConfig.Auth.Username
+	database := databaseName(requestConfig)
+	if len(database) == 0 {
+		database = username
+	}
+
+	var startup []byte
+	version := make([]byte, 4)
+	binary.BigEndian.PutUint32(version, postgresProtocolVersion)
+	startup = append(startup, version...)
+	for _, param := range []string{"user", username, "database", database, "application_name", "statusok"} {
+		startup = append(append(startup, param...), 0)
+	}
+	if err := writePostgresMessage(conn, 0, append(startup, 0)); err != nil {
+		return err
+	}
+
+	var scram *scramClient
+	password := func(data []byte) error {
+		return writePostgresMessage(conn, 'p', data)
+	}
+	for {
+		messageType, payload, err := readPostgresMessage(conn)
+		if err != nil {
+			return err
+		}
+
+		switch messageType {
+		case 'E':
+			return &replyError{Msg: "Got reply " + postgresError(payload)}
+		case 'Z':
+			return nil
+		case 'R':
+			if len(payload) < 4 {
+				return errors.New("Authentication request is invalid")
+			}
+			data := payload[4:]
+			switch code := binary.BigEndian.Uint32(payload); code {
+			case postgresAuthOk:
+			case postgresAuthCleartext:
+				if !encrypted {
+					return &replyError{Msg: "Server requested a cleartext password without TLS, use startTls"}
+				}
+				err = password(append([]byte(requestConfig.Auth.Password), 0))
+			case postgresAuthMD5:
+				if !encrypted {
+					return &replyError{Msg: "Server requested a md5 password without TLS, use startTls"}
+				}
+				if len(data) < 4 {
+					return errors.New("Authentication request is invalid")
+				}
+				err = password(append([]byte(postgresMD5Password(username, requestConfig.Auth.Password, data[:4])), 0))
+			case postgresAuthSASL:
+				if !bytes.Contains(data, []byte("SCRAM-SHA-256\x00")) {
+					return fmt.Errorf("Unsupported SASL mechanisms %s", strings.Replace(strings.Trim(string(data), "\x00"), "\x00", ", ", -1))
+				}
+				if scram, err = newScramClient(requestConfig.Auth.Password); err != nil {
+					return err
+				}
+				first := scram.clientFirst()
+				length := make([]byte, 4)
+				binary.BigEndian.PutUint32(length, uint32(len(first)))
+				err = password(append(append([]byte("SCRAM-SHA-256\x00"), length...), first...))
+			case postgresAuthSASLContinue:
+				if scram == nil {
+					return errors.New("Unexpected SASL continue")
+				}
+				var final string
+				if final, err = scram.clientFinal(string(data)); err == nil {
+					err = password([]byte(final))
+				}
+			case postgresAuthSASLFinal:
+				if scram == nil {
+					return errors.New("Unexpected SASL final")
+				}
+				err = scram.verify(string(data))
+			default:
+				return fmt.Errorf("Unsupported authentication method %d", code)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		// parameter status, backend key data and notices do not matter
+	}
+}
+
+// Runs a simple query and returns the first column of the first row
+func postgresQuery(conn net.Conn, statement string) (string, bool, error) {
+	if err := writePostgresMessage(conn, 'Q', append([]byte(statement), 0)); err != nil {
+		return "", false, err
+	}
+
+	result, null, found := "", true, false
+	var queryErr error
+	for {
+		messageType, payload, err := readPostgresMessage(conn)
+		if err != nil {
+			return "", false, err
+		}
+
+		switch messageType {
+		case 'E':
+			queryErr = &queryError{Msg: postgresError(payload)}
+		case 'D':
+			if found || len(payload) < 6 || binary.BigEndian.Uint16(payload) == 0 {
+				continue
+			}
+			found = true
+			length := int32(binary.BigEndian.Uint32(payload[2:]))
+			if length >= 0 && int(length) <= len(payload)-6 {
+				result, null = string(payload[6:6+length]), false
+			}
+		case 'Z':
+			// the server is ready for the next query
+			return result, null, queryErr
+		}
+	}
+}
+
+// Negotiates TLS if startTls is set, authenticates and runs the query
+func postgresCheck(session *checkSession, requestConfig RequestConfig) error {
+	if requestConfig.StartTLS {
+		session.done("tls")
+		if err := postgresStartTLS(session); err != nil {
+			return err
+		}
+	}
+
+	session.done("auth")
+	if err := postgresStartup(session.conn, requestConfig, session.tlsState != nil); err != nil {
+		return err
+	}
+
+	if requestConfig.Query != nil {
+		session.done("query")
+		result, null, err := postgresQuery(session.conn, requestConfig.Query.Statement)
+		if err != nil {
+			return err
+		}
+		session.done("")
+		if err := requestConfig.Query.check(result, null, session.metrics); err != nil {
+			return err
+		}
+	} else {
+		session.done("")
+	}
+
+	writePostgresMessage(session.conn, 'X', nil)
+	return nil
+}
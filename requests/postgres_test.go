@@ -0,0 +1,190 @@
+package requests
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"statusok/model"
+	"statusok/tlsutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fake postgres server authenticating the user monitor with password secret using SCRAM-SHA-256
+func fakePostgres(conn net.Conn, tlsConfig *tls.Config) {
+	var rw net.Conn = conn
+	readStartup := func() []byte {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(rw, header); err != nil {
+			return nil
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(header)-4)
+		io.ReadFull(rw, payload)
+		return payload
+	}
+	authentication := func(code uint32, data string) {
+		payload := make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, code)
+		writePostgresMessage(rw, 'R', append(payload, data...))
+	}
+	fail := func(message string) {
+		writePostgresMessage(rw, 'E', []byte("SFATAL\x00C28P01\x00M"+message+"\x00\x00"))
+	}
+
+	startup := readStartup()
+	if binary.BigEndian.Uint32(startup) == postgresSSLRequest {
+		rw.Write([]byte{'S'})
+		tlsConn := tls.Server(conn, tlsConfig)
+		if tlsConn.Handshake() != nil {
+			return
+		}
+		rw = tlsConn
+		startup = readStartup()
+	}
+	if bytes.Contains(startup, []byte("user\x00legacy\x00")) {
+		// cleartext password of old configurations
+		authentication(postgresAuthCleartext, "")
+		if _, password, _ := readPostgresMessage(rw); string(password) != "secret\x00" {
+			fail(`password authentication failed for user "legacy"`)
+			return
+		}
+		authentication(postgresAuthOk, "")
+		writePostgresMessage(rw, 'Z', []byte{'I'})
+		return
+	}
+	if !bytes.Contains(startup, []byte("user\x00monitor\x00database\x00orders\x00")) {
+		fail(`role "unknown" does not exist`)
+		return
+	}
+
+	authentication(postgresAuthSASL, "SCRAM-SHA-256\x00\x00")
+	_, initial, _ := readPostgresMessage(rw)
+	clientFirstBare := string(initial[bytes.IndexByte(initial, 0)+5+3:])
+	clientNonce := strings.TrimPrefix(clientFirstBare, "n=,r=")
+
+	salt := []byte("saltsaltsalt")
+	serverFirst := "r=" + clientNonce + "server,s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+	authentication(postgresAuthSASLContinue, serverFirst)
+
+	_, response, _ := readPostgresMessage(rw)
+	clientFinal := string(response)
+	proofIndex := strings.Index(clientFinal, ",p=")
+	proof, _ := base64.StdEncoding.DecodeString(clientFinal[proofIndex+3:])
+	authMessage := []byte(clientFirstBare + "," + serverFirst + "," + clientFinal[:proofIndex])
+
+	saltedPassword := scramSaltedPassword("secret", salt, 4096)
+	storedKey := sha256.Sum256(hmacSha256(saltedPassword, []byte("Client Key")))
+	clientKey := hmacSha256(storedKey[:], authMessage)
+	for i := range clientKey {
+		clientKey[i] ^= proof[i]
+	}
+	if check := sha256.Sum256(clientKey); !hmac.Equal(check[:], storedKey[:]) {
+		fail(`password authentication failed for user "monitor"`)
+		return
+	}
+	serverSignature := hmacSha256(hmacSha256(saltedPassword, []byte("Server Key")), authMessage)
+	authentication(postgresAuthSASLFinal, "v="+base64.StdEncoding.EncodeToString(serverSignature))
+	authentication(postgresAuthOk, "")
+	writePostgresMessage(rw, 'S', []byte("server_version\x0015.4\x00"))
+	writePostgresMessage(rw, 'Z', []byte{'I'})
+
+	for {
+		messageType, payload, err := readPostgresMessage(rw)
+		if err != nil || messageType == 'X' {
+			return
+		}
+		switch query := string(bytes.TrimRight(payload, "\x00")); query {
+		case "SELECT pg_last_xact_replay_timestamp()":
+			writePostgresMessage(rw, 'T', []byte{0, 1})
+			writePostgresMessage(rw, 'D', []byte{0, 1, 0xff, 0xff, 0xff, 0xff})
+			writePostgresMessage(rw, 'C', []byte("SELECT 1\x00"))
+		case "SELECT count(*) FROM orders":
+			writePostgresMessage(rw, 'T', []byte{0, 1})
+			writePostgresMessage(rw, 'D', []byte{0, 1, 0, 0, 0, 2, '4', '2'})
+			writePostgresMessage(rw, 'D', []byte{0, 1, 0, 0, 0, 1, '7'})
+			writePostgresMessage(rw, 'C', []byte("SELECT 2\x00"))
+		default:
+			writePostgresMessage(rw, 'E', []byte("SERROR\x00C42P01\x00Mrelation \"missing\" does not exist\x00\x00"))
+		}
+		writePostgresMessage(rw, 'Z', []byte{'I'})
+	}
+}
+
+func TestScramClient(t *testing.T) {
+	// example of RFC 7677
+	client := &scramClient{password: "pencil", clientNonce: "rOprNGfwEbeRWgbNEkqO", clientFirstBare: "n=user,r=rOprNGfwEbeRWgbNEkqO"}
+	final, err := client.clientFinal("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.Nil(t, err)
+	assert.Equal(t, "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=", final)
+	assert.Nil(t, client.verify("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="))
+	assert.Error(t, client.verify("v=AAAA"))
+
+	_, err = client.clientFinal("r=other,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.Error(t, err)
+}
+
+func TestPostgresMD5Password(t *testing.T) {
+	assert.Equal(t, "md529c6313c69e8e7dad4af92984a1f41c1", postgresMD5Password("monitor", "secret", []byte{1, 2, 3, 4}))
+}
+
+func TestPostgresCheck(t *testing.T) {
+	address, caFile, closeServer := sessionServer(t, fakePostgres)
+	defer closeServer()
+
+	auth := &AuthConfig{Type: AuthBasic, Username: "monitor", Password: "secret"}
+	requestConfig := RequestConfig{Id: 1, Type: TypePostgres, Url: "postgres://" + address + "/orders", Auth: auth, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Contains(t, result.requestInfo.Metrics, "authMs")
+
+	below := 50.0
+	requestConfig.Query = &QueryConfig{Statement: "SELECT count(*) FROM orders", Below: &below}
+	result = performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Equal(t, 42.0, result.requestInfo.Metrics["result"])
+	assert.Contains(t, result.requestInfo.Metrics, "queryMs")
+
+	requestConfig.Query = &QueryConfig{Statement: "SELECT pg_last_xact_replay_timestamp()", Matches: "."}
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Stage query failed: Query returned no result")
+	assert.Equal(t, model.CategoryAssertionFailed, result.errorInfo.Category)
+
+	requestConfig.Query = &QueryConfig{Statement: "SELECT * FROM missing"}
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, `Stage query failed: ERROR 42P01 relation "missing" does not exist`)
+	assert.Equal(t, model.CategoryQueryFailed, result.errorInfo.Category)
+
+	requestConfig.Query = nil
+	requestConfig.Auth = &AuthConfig{Type: AuthBasic, Username: "monitor", Password: "wrong"}
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, `Stage auth failed: Got reply FATAL 28P01 password authentication failed for user "monitor"`)
+	assert.Equal(t, model.CategoryAuthFailed, result.errorInfo.Category)
+
+	requestConfig.Auth = auth
+	requestConfig.StartTLS = true
+	requestConfig.TLS = &tlsutil.Config{CaFile: caFile}
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Contains(t, result.requestInfo.Metrics, "tlsMs")
+	assert.Contains(t, result.requestInfo.Metrics, "certExpiresInDays")
+
+	// a cleartext password is only sent with TLS
+	requestConfig.Auth = &AuthConfig{Type: AuthBasic, Username: "legacy", Password: "secret"}
+	result = performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+
+	requestConfig.StartTLS = false
+	requestConfig.TLS = nil
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Stage auth failed: Server requested a cleartext password without TLS, use startTls")
+	assert.Equal(t, model.CategoryAuthFailed, result.errorInfo.Category)
+}
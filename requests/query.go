@@ -0,0 +1,151 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// QueryConfig is the query of a database check and the assertion on its result. For postgres and
+// mysql the result is the first column of the first row, for redis the reply to the command or the
+// value of field in the reply e.g. of INFO.
+type QueryConfig struct {
+	Statement string   `json:"statement"`
+	Field     string   `json:"field"`
+	Equals    string   `json:"equals"`
+	Matches   string   `json:"matches"`
+	Below     *float64 `json:"below"`
+	Above     *float64 `json:"above"`
+}
+
+// check whether the query and its assertion are valid
+func (query *QueryConfig) Validate() error {
+	if len(strings.TrimSpace(query.Statement)) == 0 {
+		return errors.New("Statement of query cannot be empty")
+	}
+	if len(query.Equals) != 0 && len(query.Matches) != 0 {
+		return errors.New("Either equals or matches can be given for a query")
+	}
+	if len(query.Matches) != 0 {
+		if _, err := regexp.Compile(query.Matches); err != nil {
+			return fmt.Errorf("Query has an invalid regular expression: %s", err)
+		}
+	}
+	if query.Below != nil && query.Above != nil && *query.Below <= *query.Above {
+		return errors.New("Below of query has to be greater than above")
+	}
+	return nil
+}
+
+// Saves a numeric result as metric result and returns an error if the result does not fulfill the assertion
+func (query *QueryConfig) check(result string, null bool, metrics map[string]float64) error {
+	number, numberErr := strconv.ParseFloat(strings.TrimSpace(result), 64)
+	if !null && numberErr == nil {
+		metrics["result"] = number
+	}
+
+	if null && (len(query.Equals) != 0 || len(query.Matches) != 0 || query.Below != nil || query.Above != nil) {
+		return &resultError{Msg: "Query returned no result"}
+	}
+	if len(query.Equals) != 0 && result != query.Equals {
+		return &resultError{Msg: fmt.Sprintf("Result %q is not %q", result, query.Equals)}
+	}
+	if len(query.Matches) != 0 {
+		if re, err := regexp.Compile(query.Matches); err != nil || !re.MatchString(result) {
+			return &resultError{Msg: fmt.Sprintf("Result %q does not match %q", result, query.Matches)}
+		}
+	}
+	if query.Below != nil || query.Above != nil {
+		if numberErr != nil {
+			return &resultError{Msg: fmt.Sprintf("Result %q is not a number", result)}
+		}
+		if query.Below != nil && number >= *query.Below {
+			return &resultError{Msg: fmt.Sprintf("Result %v is not below %v", number, *query.Below)}
+		}
+		if query.Above != nil && number <= *query.Above {
+			return &resultError{Msg: fmt.Sprintf("Result %v is not above %v", number, *query.Above)}
+		}
+	}
+	return nil
+}
+
+// check the url and credentials of a redis, postgres or mysql check
+func (requestConfig *RequestConfig) validateDatabase() error {
+	target, err := url.Parse(requestConfig.Url)
+	if err != nil || len(target.Host) == 0 {
+		return errors.New("Invalid Url")
+	}
+	if requestConfig.Type == TypeRedis {
+		if target.Scheme != "redis" && target.Scheme != "rediss" {
+			return fmt.Errorf("Invalid Url %s, use redis://host:port/db or rediss://host:port/db", requestConfig.Url)
+		}
+		if requestConfig.StartTLS {
+			return errors.New("StartTLS cannot be used for a check of type redis, use a rediss:// url")
+		}
+		// AUTH sends the password as it is, never send it in plain text
+		if requestConfig.Auth != nil && target.Scheme != "rediss" {
+			return errors.New("Auth requires a rediss:// url for a check of type redis")
+		}
+		if db := strings.Trim(target.Path, "/"); len(db) != 0 {
+			if _, err := strconv.Atoi(db); err != nil {
+				return fmt.Errorf("Invalid Url %s, the database has to be a number", requestConfig.Url)
+			}
+		}
+	} else {
+		if target.Scheme != requestConfig.Type {
+			return fmt.Errorf("Invalid Url %s, use %s://host:port/database", requestConfig.Url, requestConfig.Type)
+		}
+		if requestConfig.Auth == nil {
+			return fmt.Errorf("Auth cannot be empty for a check of type %s", requestConfig.Type)
+		}
+	}
+
+	if len(requestConfig.Proxy) != 0 {
+		return fmt.Errorf("Proxy cannot be given for a check of type %s", requestConfig.Type)
+	}
+	if requestConfig.PublicKeyRetrieval && requestConfig.Type != TypeMysql {
+		return errors.New("AllowPublicKeyRetrieval can only be given for a check of type mysql")
+	}
+	if requestConfig.Auth != nil && requestConfig.Auth.Type != AuthBasic {
+		return fmt.Errorf("Only basic auth can be used for a check of type %s", requestConfig.Type)
+	}
+	if requestConfig.Query != nil {
+		if len(requestConfig.Query.Field) != 0 && requestConfig.Type != TypeRedis {
+			return errors.New("Field of query can only be given for a check of type redis")
+		}
+		if err := requestConfig.Query.Validate(); err != nil {
+			return err
+		}
+	}
+	if len(requestConfig.RequestType) == 0 {
+		requestConfig.RequestType = strings.ToUpper(requestConfig.Type)
+	}
+	return nil
+}
+
+// Name of the database of the url e.g. orders for postgres://db:5432/orders
+func databaseName(requestConfig RequestConfig) string {
+	target, err := url.Parse(requestConfig.Url)
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(target.Path, "/")
+}
+
+// connects to a redis, postgres or mysql server, authenticates and runs the query or a ping.
+// The time of each stage is saved as metric e.g. connectMs, authMs and queryMs.
+func performDatabaseCheck(requestConfig RequestConfig) attemptResult {
+	return performSessionCheck(requestConfig, func(session *checkSession) error {
+		switch requestConfig.Type {
+		case TypeRedis:
+			return redisCheck(session, requestConfig)
+		case TypePostgres:
+			return postgresCheck(session, requestConfig)
+		default:
+			return mysqlCheck(session, requestConfig)
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package requests
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"statusok/model"
+	"statusok/tlsutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryCheck(t *testing.T) {
+	below, above := 10.0, 0.0
+	query := &QueryConfig{Statement: "SELECT lag", Below: &below, Above: &above}
+	assert.Nil(t, query.Validate())
+
+	metrics := make(map[string]float64)
+	assert.Nil(t, query.check("2.5", false, metrics))
+	assert.Equal(t, 2.5, metrics["result"])
+	assert.EqualError(t, query.check("12", false, metrics), "Result 12 is not below 10")
+	assert.EqualError(t, query.check("0", false, metrics), "Result 0 is not above 0")
+	assert.EqualError(t, query.check("slow", false, metrics), `Result "slow" is not a number`)
+	assert.EqualError(t, query.check("", true, metrics), "Query returned no result")
+
+	equals := &QueryConfig{Statement: "SELECT role", Equals: "primary"}
+	assert.Nil(t, equals.check("primary", false, metrics))
+	assert.EqualError(t, equals.check("replica", false, metrics), `Result "replica" is not "primary"`)
+
+	matches := &QueryConfig{Statement: "SELECT version()", Matches: "^PostgreSQL 1[3-6]"}
+	assert.Nil(t, matches.check("PostgreSQL 15.4 on x86_64", false, metrics))
+	assert.Error(t, matches.check("PostgreSQL 9.6", false, metrics))
+
+	// without assertion every result is fine
+	assert.Nil(t, (&QueryConfig{Statement: "SELECT 1"}).check("", true, metrics))
+
+	for _, invalid := range []*QueryConfig{
+		{},
+		{Statement: "SELECT 1", Equals: "1", Matches: "1"},
+		{Statement: "SELECT 1", Matches: "("},
+		{Statement: "SELECT 1", Below: &above, Above: &below},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func TestValidateDatabase(t *testing.T) {
+	auth := &AuthConfig{Type: AuthBasic, Username: "monitor", Password: "secret"}
+	requestConfig := RequestConfig{Type: TypePostgres, Url: "postgres://db:5432/orders", Auth: auth, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	assert.Equal(t, "POSTGRES", requestConfig.RequestType)
+	assert.Equal(t, "orders", databaseName(requestConfig))
+
+	for _, valid := range []RequestConfig{
+		{Type: TypeRedis, Url: "redis://cache:6379/2", ResponseTime: 100},
+		{Type: TypeRedis, Url: "rediss://cache", Query: &QueryConfig{Statement: "INFO replication", Field: "connected_slaves", Above: new(float64)}, ResponseTime: 100},
+		{Type: TypeMysql, Url: "mysql://db", Auth: auth, StartTLS: true, ResponseTime: 100},
+	} {
+		assert.Nil(t, valid.Validate())
+	}
+
+	for _, invalid := range []RequestConfig{
+		{Type: TypeRedis, Url: "redis://cache:6379/main", ResponseTime: 100},
+		{Type: TypeRedis, Url: "redis://cache", StartTLS: true, ResponseTime: 100},
+		{Type: TypeRedis, Url: "redis://cache", Auth: auth, ResponseTime: 100},
+		{Type: TypePostgres, Url: "postgres://db", ResponseTime: 100},
+		{Type: TypeMysql, Url: "postgres://db", Auth: auth, ResponseTime: 100},
+		{Type: TypeMysql, Url: "mysql://db", Auth: &AuthConfig{Type: AuthBearer, BearerFile: "token"}, ResponseTime: 100},
+		{Type: TypeMysql, Url: "mysql://db", Auth: auth, Query: &QueryConfig{Statement: "SELECT 1", Field: "x"}, ResponseTime: 100},
+		{Type: TypePostgres, Url: "postgres://db", Auth: &AuthConfig{Type: AuthBasic, Password: "secret"}, ResponseTime: 100},
+		{Type: TypePostgres, Url: "postgres://db", Auth: auth, PublicKeyRetrieval: true, ResponseTime: 100},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func TestReadRedisReply(t *testing.T) {
+	read := func(reply string) (string, bool, error) {
+		return readRedisReply(bufio.NewReader(strings.NewReader(reply)))
+	}
+
+	reply, null, err := read("*3\r\n$4\r\nrole\r\n:2\r\n*1\r\n+nested\r\n")
+	assert.Nil(t, err)
+	assert.False(t, null)
+	assert.Equal(t, "role\n2\nnested", reply)
+
+	_, null, err = read("$-1\r\n")
+	assert.Nil(t, err)
+	assert.True(t, null)
+
+	// lengths and nesting sent by the server are bounded
+	_, _, err = read("*99999999999999\r\n")
+	assert.EqualError(t, err, "Reply of 99999999999999 elements is too large")
+	_, _, err = read("*2\r\n$1048576\r\n")
+	assert.EqualError(t, err, "Reply of 1048576 bytes is too large")
+	_, _, err = read(strings.Repeat("*1\r\n", maxRedisDepth+1) + "+OK\r\n")
+	assert.EqualError(t, err, "Reply has more than 8 nested arrays")
+}
+
+// fake redis server with a password, answering PING, SELECT and INFO
+func fakeRedis(conn net.Conn, tlsConfig *tls.Config) {
+	reader := bufio.NewReader(conn)
+	authenticated := false
+	for {
+		var args []string
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		var count int
+		fmt.Sscanf(line, "*%d", &count)
+		for i := 0; i < count; i++ {
+			reader.ReadString('\n')
+			arg, _ := reader.ReadString('\n')
+			args = append(args, strings.TrimSuffix(arg, "\r\n"))
+		}
+
+		switch {
+		case args[0] == "AUTH" && (len(args) == 3 && args[1] == "default" && args[2] == "secret" || len(args) == 2 && args[1] == "secret"):
+			authenticated = true
+			conn.Write([]byte("+OK\r\n"))
+		case args[0] == "AUTH":
+			conn.Write([]byte("-WRONGPASS invalid username-password pair or user is disabled.\r\n"))
+		case !authenticated:
+			conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+		case args[0] == "PING":
+			conn.Write([]byte("+PONG\r\n"))
+		case args[0] == "SELECT":
+			conn.Write([]byte("+OK\r\n"))
+		case args[0] == "INFO":
+			info := "# Replication\r\nrole:master\r\nconnected_slaves:2\r\n"
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(info), info)
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", args[0])
+		}
+	}
+}
+
+func TestRedisCheck(t *testing.T) {
+	address, caFile, closeServer := sessionServer(t, func(conn net.Conn, tlsConfig *tls.Config) {
+		fakeRedis(tls.Server(conn, tlsConfig), tlsConfig)
+	})
+	defer closeServer()
+
+	auth := &AuthConfig{Type: AuthBasic, Username: "default", Password: "secret"}
+	requestConfig := RequestConfig{Id: 1, Type: TypeRedis, Url: "rediss://" + address + "/1", Auth: auth, TLS: &tlsutil.Config{CaFile: caFile}, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	for _, metric := range []string{"connectMs", "tlsMs", "authMs", "selectMs", "queryMs"} {
+		assert.Contains(t, result.requestInfo.Metrics, metric)
+	}
+
+	slaves := 2.0
+	requestConfig.Query = &QueryConfig{Statement: "INFO replication", Field: "connected_slaves", Below: &slaves}
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Stage query failed: Result 2 is not below 2")
+	assert.Equal(t, model.CategoryAssertionFailed, result.errorInfo.Category)
+	assert.Equal(t, 2.0, result.errorInfo.Metrics["result"])
+
+	requestConfig.Query = &QueryConfig{Statement: "CLUSTER INFO"}
+	result = performAttempt(requestConfig)
+	assert.Equal(t, model.CategoryQueryFailed, result.errorInfo.Category)
+
+	requestConfig.Query = nil
+	requestConfig.Auth = &AuthConfig{Type: AuthBasic, Username: "default", Password: "wrong"}
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Stage auth failed: Got reply WRONGPASS invalid username-password pair or user is disabled.")
+	assert.Equal(t, model.CategoryAuthFailed, result.errorInfo.Category)
+
+	// servers before redis 6 only have a password
+	requestConfig.Auth = &AuthConfig{Type: AuthBasic, Password: "secret"}
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+
+	requestConfig.Auth = nil
+	result = performAttempt(requestConfig)
+	assert.Equal(t, model.CategoryUnexpectedStatus, result.errorInfo.Category)
+}
@@ -0,0 +1,170 @@
+package requests
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// bytes of a reply including all elements of arrays, each element counts as at least one byte
+	maxRedisReply = 1 << 20
+	// nesting of arrays in a reply
+	maxRedisDepth = 8
+)
+
+// Encodes a redis command as array of bulk strings
+func redisCommand(args ...string) []byte {
+	var command bytes.Buffer
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return command.Bytes()
+}
+
+// Reads a reply of a redis server. Arrays are returned as their elements separated by newlines,
+// null replies as null. Error replies are returned as replyError.
+func readRedisReply(r *bufio.Reader) (reply string, null bool, err error) {
+	budget := maxRedisReply
+	return readRedisValue(r, 0, &budget)
+}
+
+// Reads a value of a reply nested in depth arrays, the bytes and elements read are taken from budget
+func readRedisValue(r *bufio.Reader, depth int, budget *int) (reply string, null bool, err error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", false, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return "", false, errors.New("Reply is empty")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], false, nil
+	case '-':
+		return "", false, &replyError{Msg: "Got reply " + line[1:]}
+	case ':':
+		return line[1:], false, nil
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", false, fmt.Errorf("Invalid bulk string length %s", line[1:])
+		}
+		if length < 0 {
+			return "", true, nil
+		}
+		if length > *budget {
+			return "", false, fmt.Errorf("Reply of %d bytes is too large", length)
+		}
+		*budget -= length
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return "", false, err
+		}
+		return string(data[:length]), false, nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", false, fmt.Errorf("Invalid array length %s", line[1:])
+		}
+		if count < 0 {
+			return "", true, nil
+		}
+		if depth >= maxRedisDepth {
+			return "", false, fmt.Errorf("Reply has more than %d nested arrays", maxRedisDepth)
+		}
+		if count > *budget {
+			return "", false, fmt.Errorf("Reply of %d elements is too large", count)
+		}
+		*budget -= count
+		var elements []string
+		for i := 0; i < count; i++ {
+			element, _, err := readRedisValue(r, depth+1, budget)
+			if err != nil {
+				return "", false, err
+			}
+			elements = append(elements, element)
+		}
+		return strings.Join(elements, "\n"), false, nil
+	default:
+		return "", false, fmt.Errorf("Unknown reply type %q", line[0])
+	}
+}
+
+// Value of the field in a reply of lines like field:value as returned by INFO
+func redisField(reply string, field string) (string, bool) {
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasPrefix(line, field+":") {
+			return line[len(field)+1:], true
+		}
+	}
+	return "", false
+}
+
+// Authenticates, selects the database of the url and sends PING or the command of the query
+func redisCheck(session *checkSession, requestConfig RequestConfig) error {
+	reader := bufio.NewReader(session.conn)
+	send := func(args ...string) (string, bool, error) {
+		if _, err := session.conn.Write(redisCommand(args...)); err != nil {
+			return "", false, err
+		}
+		return readRedisReply(reader)
+	}
+
+	if auth := requestConfig.Auth; auth != nil {
+		session.done("auth")
+		args := []string{"AUTH", auth.Username, auth.Password}
+		if len(auth.Username) == 0 {
+			// password set with requirepass
+			args = []string{"AUTH", auth.Password}
+		}
+		if _, _, err := send(args...); err != nil {
+			return err
+		}
+	}
+	if db := databaseName(requestConfig); len(db) != 0 {
+		session.done("select")
+		if _, _, err := send("SELECT", db); err != nil {
+			return err
+		}
+	}
+
+	session.done("query")
+	if requestConfig.Query == nil {
+		reply, _, err := send("PING")
+		if err != nil {
+			return err
+		}
+		if reply != "PONG" {
+			return &replyError{Msg: "Got reply " + reply + " to PING"}
+		}
+		session.done("")
+		return nil
+	}
+
+	reply, null, err := send(strings.Fields(requestConfig.Query.Statement)...)
+	var replyErr *replyError
+	if errors.As(err, &replyErr) {
+		return &queryError{Msg: replyErr.Msg}
+	}
+	if err != nil {
+		return err
+	}
+	session.done("")
+
+	if len(requestConfig.Query.Field) != 0 && !null {
+		var found bool
+		if reply, found = redisField(reply, requestConfig.Query.Field); !found {
+			null = true
+		}
+	}
+	return requestConfig.Query.check(reply, null, session.metrics)
+}
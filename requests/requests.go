@@ -6,11 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
 	"statusok/database"
+	"statusok/logger"
 	"statusok/model"
+	"statusok/schedule"
+	"statusok/tlsutil"
 	"strconv"
 	"time"
 )
@@ -19,6 +24,7 @@ var (
 	RequestsList   []RequestConfig
 	requestChannel chan RequestConfig
 	throttle       chan int
+	startupProbe   bool // requests are performed once to check the config before monitoring starts
 )
 
 const (
@@ -28,27 +34,102 @@ const (
 	FormContentType = "application/x-www-form-urlencoded"
 	JsonContentType = "application/json"
 
-	DefaultTime         = "300s"
-	DefaultTimeout      = "10s"
-	DefaultResponseCode = http.StatusOK
-	DefaultConcurrency  = 1
-	DefaultUserAgent    = "Kreapptivo/Monitoring v1.0b"
+	DefaultTime          = "300s"
+	DefaultTimeout       = "10s"
+	DefaultRetryInterval = "1s"
+	DefaultResponseCode  = http.StatusOK
+	DefaultConcurrency   = 1
+	DefaultUserAgent     = "Kreapptivo/Monitoring v1.0b"
+
+	TypeHttp      = "http"
+	TypeSteps     = "steps"
+	TypeGrpc      = "grpc"
+	TypeWebSocket = "websocket"
+	TypeExec      = "exec"
+	TypeSmtp      = "smtp"
+	TypeImap      = "imap"
+	TypePop3      = "pop3"
+	TypeRedis     = "redis"
+	TypePostgres  = "postgres"
+	TypeMysql     = "mysql"
+	TypeUdp       = "udp"
+	TypeNtp       = "ntp"
+	TypeMetric    = "metric"
+	TypeCrawl     = "crawl"
+
+	SpreadEven   = "even"
+	SpreadRandom = "random"
 )
 
 type RequestConfig struct {
 	Id                  int
-	Url                 string            `json:"url"`
-	RequestType         string            `json:"requestType"`
-	Headers             map[string]string `json:"headers"`
-	FormParams          map[string]string `json:"formParams"`
-	UrlParams           map[string]string `json:"urlParams"`
-	ResponseCode        int               `json:"responseCode"`
-	ResponseTime        int64             `json:"responseTime"`
-	CheckEvery          string            `json:"checkEvery"`
-	_checkEvery         time.Duration     `json:"-"`
-	Timeout             string            `json:"timeout"`
-	_timeout            time.Duration     `json:"-"`
-	MedianResponseCount int               `json:"medianResponseCount"`
+	Type                string                  `json:"type"`
+	Name                string                  `json:"name"`
+	Group               string                  `json:"group"`
+	DependsOn           []string                `json:"dependsOn"`
+	Url                 string                  `json:"url"`
+	RequestType         string                  `json:"requestType"`
+	Headers             map[string]string       `json:"headers"`
+	Auth                *AuthConfig             `json:"auth"`
+	TLS                 *tlsutil.Config         `json:"tls"`
+	Proxy               string                  `json:"proxy"`
+	SourceAddress       string                  `json:"sourceAddress"`
+	StartTLS            bool                    `json:"startTls"`
+	PublicKeyRetrieval  bool                    `json:"allowPublicKeyRetrieval"`
+	CertExpiryDays      int                     `json:"certExpiryDays"`
+	_transport          http.RoundTripper       `json:"-"`
+	FormParams          map[string]string       `json:"formParams"`
+	Body                string                  `json:"body"`
+	JsonBody            json.RawMessage         `json:"jsonBody"`
+	BodyFile            string                  `json:"bodyFile"`
+	Multipart           *MultipartBody          `json:"multipart"`
+	Steps               []Step                  `json:"steps"`
+	Service             string                  `json:"service"`
+	WebSocket           *WebSocketConfig        `json:"websocket"`
+	Exec                *ExecConfig             `json:"exec"`
+	Query               *QueryConfig            `json:"query"`
+	Udp                 *UdpConfig              `json:"udp"`
+	Ntp                 *NtpConfig              `json:"ntp"`
+	Metric              *MetricConfig           `json:"metric"`
+	Crawl               *CrawlConfig            `json:"crawl"`
+	_jar                http.CookieJar          `json:"-"`
+	UrlParams           map[string]string       `json:"urlParams"`
+	ResponseCode        int                     `json:"responseCode"`
+	ResponseCodes       []string                `json:"responseCodes"`
+	_responseCodes      *responseCodeMatcher    `json:"-"`
+	HeaderAssertions    []HeaderAssertion       `json:"headerAssertions"`
+	Content             *ContentConfig          `json:"content"`
+	Audit               *AuditConfig            `json:"audit"`
+	ResponseTime        int64                   `json:"responseTime"`
+	CheckEvery          string                  `json:"checkEvery"`
+	_checkEvery         time.Duration           `json:"-"`
+	Schedule            string                  `json:"schedule"`
+	Timezone            string                  `json:"timezone"`
+	_schedule           *schedule.Schedule      `json:"-"`
+	Timeout             string                  `json:"timeout"`
+	_timeout            time.Duration           `json:"-"`
+	Retries             int                     `json:"retries"`
+	RetryInterval       string                  `json:"retryInterval"`
+	_retryInterval      time.Duration           `json:"-"`
+	MedianResponseCount int                     `json:"medianResponseCount"`
+	Anomaly             *database.AnomalyConfig `json:"anomaly"`
+}
+
+// Returns the response codes given by responseCodes or responseCode
+func (requestConfig RequestConfig) expectedResponseCodes() *responseCodeMatcher {
+	if requestConfig._responseCodes != nil {
+		return requestConfig._responseCodes
+	}
+	if len(requestConfig.ResponseCodes) != 0 {
+		if matcher, err := parseResponseCodes(requestConfig.ResponseCodes); err == nil {
+			return matcher
+		}
+	}
+	code := requestConfig.ResponseCode
+	if code == 0 {
+		code = DefaultResponseCode
+	}
+	return &responseCodeMatcher{include: []codeRange{{code, code}}, spec: []string{strconv.Itoa(code)}}
 }
 
 // Set Id for request
@@ -58,34 +139,155 @@ func (requestConfig *RequestConfig) SetId(id int) {
 
 // check whether all requestConfig fields are valid
 func (requestConfig *RequestConfig) Validate() error {
-	if len(requestConfig.Url) == 0 {
-		return errors.New("Invalid Url")
-	}
+	switch requestConfig.Type {
+	case "", TypeHttp:
+		requestConfig.Type = TypeHttp
 
-	if _, err := url.Parse(requestConfig.Url); err != nil {
-		return errors.New("Invalid Url")
-	}
+		if len(requestConfig.Url) == 0 {
+			return errors.New("Invalid Url")
+		}
 
-	if len(requestConfig.RequestType) == 0 {
-		return errors.New("RequestType cannot be empty")
+		if _, err := url.Parse(requestConfig.Url); err != nil {
+			return errors.New("Invalid Url")
+		}
+
+		if len(requestConfig.RequestType) == 0 {
+			return errors.New("RequestType cannot be empty")
+		}
+	case TypeSteps:
+		if err := requestConfig.validateSteps(); err != nil {
+			return err
+		}
+	case TypeGrpc:
+		if err := requestConfig.validateGrpc(); err != nil {
+			return err
+		}
+	case TypeWebSocket:
+		if err := requestConfig.validateWebSocket(); err != nil {
+			return err
+		}
+	case TypeExec:
+		if err := requestConfig.validateExec(); err != nil {
+			return err
+		}
+	case TypeSmtp, TypeImap, TypePop3:
+		if err := requestConfig.validateMail(); err != nil {
+			return err
+		}
+	case TypeRedis, TypePostgres, TypeMysql:
+		if err := requestConfig.validateDatabase(); err != nil {
+			return err
+		}
+	case TypeUdp:
+		if err := requestConfig.validateUdp(); err != nil {
+			return err
+		}
+	case TypeNtp:
+		if err := requestConfig.validateNtp(); err != nil {
+			return err
+		}
+	case TypeMetric:
+		if err := requestConfig.validateMetric(); err != nil {
+			return err
+		}
+	case TypeCrawl:
+		if err := requestConfig.validateCrawl(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown check type %s", requestConfig.Type)
 	}
 
 	if requestConfig.ResponseTime == 0 {
 		return errors.New("ResponseTime cannot be empty")
 	}
 
-	if requestConfig.ResponseCode == 0 {
+	if requestConfig.CertExpiryDays < 0 {
+		return errors.New("CertExpiryDays cannot be negative")
+	}
+
+	if len(requestConfig.ResponseCodes) != 0 {
+		if requestConfig.ResponseCode != 0 {
+			return errors.New("Either ResponseCode or ResponseCodes can be given")
+		}
+		var err error
+		if requestConfig._responseCodes, err = parseResponseCodes(requestConfig.ResponseCodes); err != nil {
+			return err
+		}
+	} else if requestConfig.ResponseCode == 0 {
 		requestConfig.ResponseCode = DefaultResponseCode
 	}
 
-	if len(requestConfig.CheckEvery) == 0 {
-		requestConfig.CheckEvery = DefaultTime
+	if requestConfig.Auth != nil {
+		if len(requestConfig.Headers[Authorization]) != 0 {
+			return errors.New("Either Auth or an Authorization header can be given")
+		}
+		// redis servers without users, before version 6, only take a password
+		passwordOnly := requestConfig.Type == TypeRedis && requestConfig.Auth.Type == AuthBasic && len(requestConfig.Auth.Username) == 0
+		if err := requestConfig.Auth.Validate(); err != nil && !passwordOnly {
+			return err
+		}
+	}
+
+	if requestConfig.TLS != nil {
+		if err := requestConfig.TLS.Validate(); err != nil {
+			return err
+		}
 	}
+
 	var err error
-	if requestConfig._checkEvery, err = time.ParseDuration(requestConfig.CheckEvery); err != nil {
-		return fmt.Errorf("CheckEvery format is invalid %s", err)
+	if err := requestConfig.validateBody(); err != nil {
+		return err
+	}
+
+	for _, assertion := range requestConfig.HeaderAssertions {
+		if err := assertion.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if requestConfig.Content != nil {
+		if requestConfig.Type != TypeHttp {
+			return errors.New("Content can only be given for a check of type http")
+		}
+		if err := requestConfig.Content.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if requestConfig.Audit != nil {
+		if requestConfig.Type != TypeHttp {
+			return errors.New("Audit can only be given for a check of type http")
+		}
+		if err := requestConfig.Audit.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if len(requestConfig.Schedule) != 0 {
+		// cron expression instead of checkEvery
+		if len(requestConfig.CheckEvery) != 0 {
+			return errors.New("Either CheckEvery or Schedule can be given")
+		}
+		location := time.Local
+		if len(requestConfig.Timezone) != 0 {
+			if location, err = time.LoadLocation(requestConfig.Timezone); err != nil {
+				return fmt.Errorf("Timezone is invalid %s", err)
+			}
+		}
+		if requestConfig._schedule, err = schedule.Parse(requestConfig.Schedule, location); err != nil {
+			return fmt.Errorf("Schedule format is invalid %s", err)
+		}
+		fmt.Printf("Schedule: %s\n", requestConfig.Schedule)
+	} else {
+		if len(requestConfig.CheckEvery) == 0 {
+			requestConfig.CheckEvery = DefaultTime
+		}
+		if requestConfig._checkEvery, err = time.ParseDuration(requestConfig.CheckEvery); err != nil {
+			return fmt.Errorf("CheckEvery format is invalid %s", err)
+		}
+		fmt.Printf("Check every: %s\n", fmtDuration(requestConfig._checkEvery))
 	}
-	fmt.Printf("Check every: %s\n", fmtDuration(requestConfig._checkEvery))
 
 	if len(requestConfig.Timeout) == 0 {
 		requestConfig.Timeout = DefaultTimeout
@@ -95,6 +297,31 @@ func (requestConfig *RequestConfig) Validate() error {
 	}
 	fmt.Printf("Request timeout: %s\n", fmtDuration(requestConfig._timeout))
 
+	if requestConfig.Retries < 0 {
+		return errors.New("Retries cannot be negative")
+	}
+	if len(requestConfig.RetryInterval) == 0 {
+		requestConfig.RetryInterval = DefaultRetryInterval
+	}
+	if requestConfig._retryInterval, err = time.ParseDuration(requestConfig.RetryInterval); err != nil {
+		return fmt.Errorf("RetryInterval format is invalid %s", err)
+	}
+
+	// the transport is shared by all requests of the check, created once the timeout is known
+	if requestConfig.Type == TypeGrpc {
+		transport, err := newGrpcTransport(*requestConfig)
+		if err != nil {
+			return err
+		}
+		requestConfig._transport = transport
+	} else if (requestConfig.Type == TypeHttp || requestConfig.Type == TypeSteps || requestConfig.Type == TypeMetric || requestConfig.Type == TypeCrawl) && (requestConfig.TLS != nil || len(requestConfig.Proxy) != 0 || len(requestConfig.SourceAddress) != 0) {
+		transport, err := newTransport(*requestConfig)
+		if err != nil {
+			return err
+		}
+		requestConfig._transport = transport
+	}
+
 	return nil
 }
 
@@ -129,6 +356,8 @@ func RequestsInit(data []RequestConfig, concurrency int) {
 	fmt.Println("\nSending requests to apis.....making sure everything is right before we start monitoring")
 	fmt.Println("Api Count: ", len(data))
 
+	startupProbe = true
+	defer func() { startupProbe = false }()
 	for i, requestConfig := range data {
 		fmt.Printf("Request #%d:%s %s\n", i, requestConfig.RequestType, requestConfig.Url)
 
@@ -148,19 +377,79 @@ func RequestsInit(data []RequestConfig, concurrency int) {
 	fmt.Println("All requests Successfull")
 }
 
-// Start monitoring by calling createTicker method for each request
-func StartMonitoring() {
+// Start monitoring by calling createTicker method for each request.
+// With spread "even" the first requests with the same interval are evenly distributed
+// across the interval, with "random" each one is delayed randomly within its interval.
+func StartMonitoring(spread string) {
 	fmt.Printf("Started Monitoring %d apis .....\n", len(RequestsList))
 
 	go listenToRequestChannel()
 
-	for _, requestConfig := range RequestsList {
-		go createTicker(requestConfig)
+	startDelays := getStartDelays(RequestsList, spread)
+
+	for i, requestConfig := range RequestsList {
+		go createTicker(requestConfig, startDelays[i])
 	}
 }
 
-// A time ticker writes data to request channel for every request.CheckEvery seconds
-func createTicker(requestConfig RequestConfig) {
+// Calculates the delay before the first request for each request
+func getStartDelays(reqs []RequestConfig, spread string) []time.Duration {
+	delays := make([]time.Duration, len(reqs))
+
+	// requests are spread across their interval, scheduled ones across the minute they are due
+	interval := func(requestConfig RequestConfig) time.Duration {
+		if requestConfig._schedule != nil {
+			return time.Minute
+		}
+		return requestConfig._checkEvery
+	}
+
+	switch spread {
+	case SpreadEven:
+		byInterval := make(map[time.Duration][]int)
+		for i, requestConfig := range reqs {
+			byInterval[interval(requestConfig)] = append(byInterval[interval(requestConfig)], i)
+		}
+		for d, indices := range byInterval {
+			for n, i := range indices {
+				delays[i] = d * time.Duration(n) / time.Duration(len(indices))
+			}
+		}
+	case SpreadRandom:
+		for i, requestConfig := range reqs {
+			if d := interval(requestConfig); d > 0 {
+				delays[i] = time.Duration(rand.Int63n(int64(d)))
+			}
+		}
+	}
+
+	return delays
+}
+
+// Validate the spread mode given in the config file
+func ValidateSpread(spread string) error {
+	if spread != "" && spread != SpreadEven && spread != SpreadRandom {
+		return fmt.Errorf("Invalid spread %s, use %s or %s", spread, SpreadEven, SpreadRandom)
+	}
+	return nil
+}
+
+// A time ticker writes data to request channel for every request.CheckEvery seconds.
+// Requests with a schedule are written to the channel whenever the schedule is due.
+func createTicker(requestConfig RequestConfig, startDelay time.Duration) {
+	if requestConfig._schedule != nil {
+		for {
+			next := requestConfig._schedule.Next(time.Now())
+			if next.IsZero() {
+				return
+			}
+			time.Sleep(time.Until(next) + startDelay)
+			requestChannel <- requestConfig
+		}
+	}
+
+	time.Sleep(startDelay)
+
 	var ticker *time.Ticker = time.NewTicker(requestConfig._checkEvery)
 	quit := make(chan struct{})
 	for {
@@ -187,7 +476,42 @@ func listenToRequestChannel() {
 	}
 }
 
-// takes the date from requestConfig and creates http request and executes it
+// result of a single attempt to perform a request
+type attemptResult struct {
+	requestInfo model.RequestInfo
+	errorInfo   *model.ErrorInfo // nil if the attempt succeeded
+	err         error
+	transient   bool // failure might not occur again, e.g. timeouts or 5xx responses
+	elapsed     time.Duration
+	headers     map[string]string
+	response    *http.Response // response with its body already read into body
+	body        []byte
+}
+
+// Converts the result of an attempt to the record saved for every attempt
+func (result attemptResult) resultInfo(requestConfig RequestConfig, attempt int) model.ResultInfo {
+	resultInfo := model.ResultInfo{
+		Id:             requestConfig.Id,
+		Url:            requestConfig.Url,
+		RequestType:    requestConfig.RequestType,
+		Success:        result.errorInfo == nil,
+		ResponseCode:   result.requestInfo.ResponseCode,
+		ResponseTimeMs: result.elapsed.Milliseconds(),
+		Headers:        result.headers,
+		Attempt:        attempt,
+		Metrics:        result.requestInfo.Metrics,
+	}
+	if result.errorInfo != nil {
+		resultInfo.ResponseCode = result.errorInfo.ResponseCode
+		resultInfo.Category = result.errorInfo.Category
+		resultInfo.Headers = result.errorInfo.Headers
+		resultInfo.Metrics = result.errorInfo.Metrics
+	}
+	return resultInfo
+}
+
+// takes the date from requestConfig and creates http request and executes it.
+// Transient failures are retried requestConfig.Retries times before the error is added to the database.
 func PerformRequest(requestConfig RequestConfig, throttle chan int) error {
 	// Remove value from throttle channel when request is completed
 	defer func() {
@@ -196,32 +520,132 @@ func PerformRequest(requestConfig RequestConfig, throttle chan int) error {
 		}
 	}()
 
+	var result attemptResult
+	attempt := 1
+	for ; ; attempt++ {
+		result = performAttempt(requestConfig)
+		go database.AddResultInfo(result.resultInfo(requestConfig, attempt))
+
+		if result.errorInfo == nil || !result.transient || attempt > requestConfig.Retries {
+			break
+		}
+
+		logger.LogRetry(*result.errorInfo, attempt, result.elapsed.Milliseconds())
+		// other requests can be performed while waiting for the retry
+		if throttle != nil {
+			<-throttle
+		}
+		time.Sleep(requestConfig._retryInterval)
+		if throttle != nil {
+			throttle <- 1
+		}
+	}
+
+	if result.errorInfo != nil {
+		result.errorInfo.Attempts = attempt
+		go func(errorInfo model.ErrorInfo) {
+			// a changed content stays reported until a notification about it was sent
+			if database.AddErrorInfo(errorInfo) && errorInfo.Category == model.CategoryContentChanged {
+				acceptContentChange(requestConfig)
+			}
+		}(*result.errorInfo)
+		return result.err
+	}
+
+	// Request succesfull. Add entry to Database
+	result.requestInfo.Attempts = attempt
+	go database.AddRequestInfo(result.requestInfo)
+
+	return nil
+}
+
+// performs a single attempt of the check depending on its type
+func performAttempt(requestConfig RequestConfig) attemptResult {
+	switch requestConfig.Type {
+	case TypeSteps:
+		return performSteps(requestConfig)
+	case TypeGrpc:
+		return performGrpcCheck(requestConfig)
+	case TypeWebSocket:
+		return performWebSocketCheck(requestConfig)
+	case TypeExec:
+		return performExecCheck(requestConfig)
+	case TypeSmtp, TypeImap, TypePop3:
+		return performMailCheck(requestConfig)
+	case TypeRedis, TypePostgres, TypeMysql:
+		return performDatabaseCheck(requestConfig)
+	case TypeUdp:
+		return performUdpCheck(requestConfig)
+	case TypeNtp:
+		return performNtpCheck(requestConfig)
+	case TypeMetric:
+		return performMetricCheck(requestConfig)
+	case TypeCrawl:
+		return performCrawlCheck(requestConfig)
+	default:
+		return performHttpRequest(requestConfig)
+	}
+}
+
+// performs a single attempt of the request
+func performHttpRequest(requestConfig RequestConfig) attemptResult {
 	var request *http.Request
 	var reqErr error
 
 	if len(requestConfig.FormParams) == 0 {
-		// formParams create a request
-		request, reqErr = http.NewRequest(requestConfig.RequestType,
-			requestConfig.Url,
-			nil)
-	} else {
-		if requestConfig.Headers[ContentType] == JsonContentType {
-			// create a request using using formParams
-
-			jsonBody, jsonErr := GetJsonParamsBody(requestConfig.FormParams)
-			if jsonErr != nil {
-				// Not able to create Request object.Add Error to Database
-				go database.AddErrorInfo(model.ErrorInfo{
+		// create a request with body, jsonBody, bodyFile or multipart if given
+		body, contentType, bodyErr := getRequestBody(requestConfig)
+		if bodyErr != nil {
+			// Not able to create Request body.Add Error to Database
+			return attemptResult{
+				errorInfo: &model.ErrorInfo{
 					Id:           requestConfig.Id,
 					Url:          requestConfig.Url,
 					RequestType:  requestConfig.RequestType,
 					ResponseCode: 0,
 					ResponseBody: "",
 					Reason:       database.ErrCreateRequest,
-					OtherInfo:    jsonErr.Error(),
-				})
+					Category:     model.CategoryInvalidRequest,
+					OtherInfo:    bodyErr.Error(),
+				},
+				err: bodyErr,
+			}
+		}
+
+		if body == nil {
+			request, reqErr = http.NewRequest(requestConfig.RequestType,
+				requestConfig.Url,
+				nil)
+		} else {
+			request, reqErr = http.NewRequest(requestConfig.RequestType,
+				requestConfig.Url,
+				body)
 
-				return jsonErr
+			// Content type of the body unless given in headers
+			if reqErr == nil && requestConfig.Headers[ContentType] == "" {
+				request.Header.Set(ContentType, contentType)
+			}
+		}
+	} else {
+		if requestConfig.Headers[ContentType] == JsonContentType {
+			// create a request using using formParams
+
+			jsonBody, jsonErr := GetJsonParamsBody(requestConfig.FormParams)
+			if jsonErr != nil {
+				// Not able to create Request object.Add Error to Database
+				return attemptResult{
+					errorInfo: &model.ErrorInfo{
+						Id:           requestConfig.Id,
+						Url:          requestConfig.Url,
+						RequestType:  requestConfig.RequestType,
+						ResponseCode: 0,
+						ResponseBody: "",
+						Reason:       database.ErrCreateRequest,
+						Category:     model.CategoryInvalidRequest,
+						OtherInfo:    jsonErr.Error(),
+					},
+					err: jsonErr,
+				}
 			}
 			request, reqErr = http.NewRequest(requestConfig.RequestType,
 				requestConfig.Url,
@@ -235,14 +659,32 @@ func PerformRequest(requestConfig RequestConfig, throttle chan int) error {
 				requestConfig.Url,
 				bytes.NewBufferString(formParams.Encode()))
 
-			request.Header.Add(ContentLength, strconv.Itoa(len(formParams.Encode())))
+			if reqErr == nil {
+				request.Header.Add(ContentLength, strconv.Itoa(len(formParams.Encode())))
 
-			if requestConfig.Headers[ContentType] != "" {
-				// Add content type to header if user doesnt mention it config file
-				// Default content type application/x-www-form-urlencoded
-				request.Header.Add(ContentType, FormContentType)
+				if requestConfig.Headers[ContentType] != "" {
+					// Add content type to header if user doesnt mention it config file
+					// Default content type application/x-www-form-urlencoded
+					request.Header.Add(ContentType, FormContentType)
+				}
 			}
+		}
+	}
 
+	if reqErr != nil {
+		// Not able to create Request object.Add Error to Database
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:           requestConfig.Id,
+				Url:          requestConfig.Url,
+				RequestType:  requestConfig.RequestType,
+				ResponseCode: 0,
+				ResponseBody: "",
+				Reason:       database.ErrCreateRequest,
+				Category:     model.CategoryInvalidRequest,
+				OtherInfo:    reqErr.Error(),
+			},
+			err: reqErr,
 		}
 	}
 
@@ -252,21 +694,6 @@ func PerformRequest(requestConfig RequestConfig, throttle chan int) error {
 		request.Header.Add(UserAgent, DefaultUserAgent)
 	}
 
-	if reqErr != nil {
-		// Not able to create Request object.Add Error to Database
-		go database.AddErrorInfo(model.ErrorInfo{
-			Id:           requestConfig.Id,
-			Url:          requestConfig.Url,
-			RequestType:  requestConfig.RequestType,
-			ResponseCode: 0,
-			ResponseBody: "",
-			Reason:       database.ErrCreateRequest,
-			OtherInfo:    reqErr.Error(),
-		})
-
-		return reqErr
-	}
-
 	// add url parameters to query if present
 	if len(requestConfig.UrlParams) != 0 {
 		urlParams := GetUrlValues(requestConfig.UrlParams)
@@ -276,12 +703,47 @@ func PerformRequest(requestConfig RequestConfig, throttle chan int) error {
 	// Add headers to the request
 	AddHeaders(request, requestConfig.Headers)
 
-	client := &http.Client{
-		Timeout: requestConfig._timeout,
+	client, clientErr := newHttpClient(requestConfig)
+	if clientErr != nil {
+		// Not able to create the client e.g. the certificates cannot be loaded. Add Error to Database
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:           requestConfig.Id,
+				Url:          requestConfig.Url,
+				RequestType:  requestConfig.RequestType,
+				ResponseCode: 0,
+				ResponseBody: "",
+				Reason:       database.ErrCreateRequest,
+				Category:     model.CategoryInvalidRequest,
+				OtherInfo:    clientErr.Error(),
+			},
+			err: clientErr,
+		}
+	}
+
+	if requestConfig.Auth != nil {
+		if authErr := requestConfig.Auth.apply(request, client); authErr != nil {
+			// Not able to get credentials e.g. from the token endpoint. Add Error to Database
+			return attemptResult{
+				errorInfo: &model.ErrorInfo{
+					Id:           requestConfig.Id,
+					Url:          requestConfig.Url,
+					RequestType:  requestConfig.RequestType,
+					ResponseCode: 0,
+					ResponseBody: "",
+					Reason:       database.ErrTokenFetch,
+					Category:     model.CategoryTokenFetch,
+					OtherInfo:    authErr.Error(),
+				},
+				err:       authErr,
+				transient: true,
+			}
+		}
 	}
 	start := time.Now()
 
-	getResponse, respErr := client.Do(request)
+	getResponse, respErr := requestConfig.Auth.do(client, request)
+	elapsed := time.Since(start)
 
 	if respErr != nil {
 		// Request failed . Add error info to database
@@ -291,47 +753,196 @@ func PerformRequest(requestConfig RequestConfig, throttle chan int) error {
 		} else {
 			statusCode = getResponse.StatusCode
 		}
-		go database.AddErrorInfo(model.ErrorInfo{
-			Id:           requestConfig.Id,
-			Url:          requestConfig.Url,
-			RequestType:  requestConfig.RequestType,
-			ResponseCode: statusCode,
-			ResponseBody: convertResponseToString(getResponse),
-			Reason:       database.ErrDoRequest,
-			OtherInfo:    respErr.Error(),
-		})
-		return respErr
+		category := classifyError(respErr)
+		reason := database.ErrDoRequest
+		if category == model.CategoryTimeout {
+			reason = database.ErrTimeout
+		}
+		if len(requestConfig.Proxy) != 0 && isProxyError(respErr) {
+			category = model.CategoryProxy
+			reason = database.ErrProxy
+		}
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:             requestConfig.Id,
+				Url:            requestConfig.Url,
+				RequestType:    requestConfig.RequestType,
+				ResponseCode:   statusCode,
+				ResponseTimeMs: elapsed.Milliseconds(),
+				Headers:        getResponseHeaders(getResponse),
+				ResponseBody:   convertResponseToString(getResponse),
+				Reason:         reason,
+				Category:       category,
+				OtherInfo:      respErr.Error(),
+			},
+			err:       respErr,
+			transient: true,
+			elapsed:   elapsed,
+		}
 	}
 
 	defer getResponse.Body.Close()
 
-	if getResponse.StatusCode != requestConfig.ResponseCode {
+	expectedCodes := requestConfig.expectedResponseCodes()
+	if !expectedCodes.matches(getResponse.StatusCode) {
 		// Response code is not the expected one .Add Error to database
-		go database.AddErrorInfo(model.ErrorInfo{
-			Id:           requestConfig.Id,
-			Url:          requestConfig.Url,
-			RequestType:  requestConfig.RequestType,
-			ResponseCode: getResponse.StatusCode,
-			ResponseBody: convertResponseToString(getResponse),
-			Reason:       errResponseCode(getResponse.StatusCode, requestConfig.ResponseCode),
-			OtherInfo:    "",
-		})
-		return errResponseCode(getResponse.StatusCode, requestConfig.ResponseCode)
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:             requestConfig.Id,
+				Url:            requestConfig.Url,
+				RequestType:    requestConfig.RequestType,
+				ResponseCode:   getResponse.StatusCode,
+				ResponseTimeMs: elapsed.Milliseconds(),
+				Headers:        getResponseHeaders(getResponse),
+				ResponseBody:   convertResponseToString(getResponse),
+				Reason:         errResponseCode(getResponse.StatusCode, expectedCodes),
+				Category:       statusCategory(requestConfig, getResponse.StatusCode),
+				OtherInfo:      "",
+			},
+			err:       errResponseCode(getResponse.StatusCode, expectedCodes),
+			transient: getResponse.StatusCode >= http.StatusInternalServerError,
+			elapsed:   elapsed,
+		}
 	}
 
-	elapsed := time.Since(start)
+	if assertionErr := checkHeaderAssertions(requestConfig.HeaderAssertions, getResponse.Header); assertionErr != nil {
+		// Response header is not the expected one. Add Error to database
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:             requestConfig.Id,
+				Url:            requestConfig.Url,
+				RequestType:    requestConfig.RequestType,
+				ResponseCode:   getResponse.StatusCode,
+				ResponseTimeMs: elapsed.Milliseconds(),
+				Headers:        getResponseHeaders(getResponse),
+				ResponseBody:   convertResponseToString(getResponse),
+				Reason:         assertionErr,
+				Category:       model.CategoryAssertionFailed,
+				OtherInfo:      "",
+			},
+			err:     assertionErr,
+			elapsed: elapsed,
+		}
+	}
 
-	// Request succesfull. Add entry to Database
-	go database.AddRequestInfo(model.RequestInfo{
-		Id:                   requestConfig.Id,
-		Url:                  requestConfig.Url,
-		RequestType:          requestConfig.RequestType,
-		ResponseCode:         getResponse.StatusCode,
-		ResponseTimeMs:       elapsed.Milliseconds(),
-		ExpectedResponseTime: requestConfig.ResponseTime,
-	})
+	// Read the whole response so broken transfers are noticed
+	body, bodyErr := ioutil.ReadAll(getResponse.Body)
+	if bodyErr != nil {
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:             requestConfig.Id,
+				Url:            requestConfig.Url,
+				RequestType:    requestConfig.RequestType,
+				ResponseCode:   getResponse.StatusCode,
+				ResponseTimeMs: elapsed.Milliseconds(),
+				Headers:        getResponseHeaders(getResponse),
+				ResponseBody:   "",
+				Reason:         database.ErrReadResponse,
+				Category:       model.CategoryBodyRead,
+				OtherInfo:      bodyErr.Error(),
+			},
+			err:       bodyErr,
+			transient: true,
+			elapsed:   elapsed,
+		}
+	}
 
-	return nil
+	var metrics map[string]float64
+	if getResponse.TLS != nil {
+		metrics = make(map[string]float64)
+	}
+	if expiryErr := checkCertificateExpiry(requestConfig, getResponse.TLS, metrics); expiryErr != nil {
+		// Certificate expires within certExpiryDays. Add Error to database
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:             requestConfig.Id,
+				Url:            requestConfig.Url,
+				RequestType:    requestConfig.RequestType,
+				ResponseCode:   getResponse.StatusCode,
+				ResponseTimeMs: elapsed.Milliseconds(),
+				Headers:        getResponseHeaders(getResponse),
+				ResponseBody:   string(body),
+				Reason:         database.ErrCertExpiry,
+				Category:       model.CategoryCertificateExpiry,
+				OtherInfo:      expiryErr.Error(),
+				Metrics:        metrics,
+			},
+			err:     expiryErr,
+			elapsed: elapsed,
+		}
+	}
+
+	// Content changes while StatusOk was stopped are reported by the first scheduled check, not the startup probe
+	if requestConfig.Content != nil && !startupProbe {
+		if metrics == nil {
+			metrics = make(map[string]float64)
+		}
+		if category, contentErr := checkContent(requestConfig, body, time.Now(), metrics); contentErr != nil {
+			// Content changed from the baseline or did not change within maxAge. Add Error to database
+			reason := database.ErrContentChange
+			if category == model.CategoryContentStale {
+				reason = database.ErrContentStale
+			} else if category == model.CategoryBodyRead {
+				reason = database.ErrReadResponse
+			}
+			return attemptResult{
+				errorInfo: &model.ErrorInfo{
+					Id:             requestConfig.Id,
+					Url:            requestConfig.Url,
+					RequestType:    requestConfig.RequestType,
+					ResponseCode:   getResponse.StatusCode,
+					ResponseTimeMs: elapsed.Milliseconds(),
+					Headers:        getResponseHeaders(getResponse),
+					ResponseBody:   string(body),
+					Reason:         reason,
+					Category:       category,
+					OtherInfo:      contentErr.Error(),
+					Metrics:        metrics,
+				},
+				err:     contentErr,
+				elapsed: elapsed,
+			}
+		}
+	}
+
+	// Findings of the audit do not fail the check, new ones are notified
+	var findings []string
+	if requestConfig.Audit != nil {
+		findings = requestConfig.Audit.findings(getResponse, client)
+		if metrics == nil {
+			metrics = make(map[string]float64)
+		}
+		metrics["auditFindings"] = float64(len(findings))
+	}
+
+	return attemptResult{
+		requestInfo: model.RequestInfo{
+			Id:                   requestConfig.Id,
+			Url:                  requestConfig.Url,
+			RequestType:          requestConfig.RequestType,
+			ResponseCode:         getResponse.StatusCode,
+			ResponseTimeMs:       elapsed.Milliseconds(),
+			ExpectedResponseTime: requestConfig.ResponseTime,
+			Metrics:              metrics,
+			AuditFindings:        findings,
+		},
+		elapsed:  elapsed,
+		headers:  getResponseHeaders(getResponse),
+		response: getResponse,
+		body:     body,
+	}
+}
+
+// first value of each response header
+func getResponseHeaders(resp *http.Response) map[string]string {
+	if resp == nil {
+		return nil
+	}
+	headers := make(map[string]string, len(resp.Header))
+	for key := range resp.Header {
+		headers[key] = resp.Header.Get(key)
+	}
+	return headers
 }
 
 // convert response body to string
@@ -385,7 +996,15 @@ func GetJsonParamsBody(params map[string]string) (io.Reader, error) {
 	return bytes.NewBuffer(data), nil
 }
 
+// Category of an unexpected response code, the proxy rejecting the credentials is a proxy error
+func statusCategory(requestConfig RequestConfig, status int) string {
+	if len(requestConfig.Proxy) != 0 && status == http.StatusProxyAuthRequired {
+		return model.CategoryProxy
+	}
+	return model.CategoryUnexpectedStatus
+}
+
 // creates an error when response code from server is not equal to response code mentioned in config file
-func errResponseCode(status int, expectedStatus int) error {
+func errResponseCode(status int, expectedStatus *responseCodeMatcher) error {
 	return fmt.Errorf("Got Response code %v. Expected Response Code %v ", status, expectedStatus)
 }
@@ -1,12 +1,17 @@
 package requests
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestRequestsInit(t *testing.T) {
 	data := make([]RequestConfig, 0)
-	google := RequestConfig{1, "http://google.com", "GET", nil, nil, nil, 200, 100, "1s", 1, "", 0, 0}
+	google := RequestConfig{Id: 1, Url: "http://google.com", RequestType: "GET", ResponseCode: 200, ResponseTime: 100, CheckEvery: "1s", _checkEvery: 1}
 	data = append(data, google)
 
 	RequestsInit(data, 0)
@@ -17,7 +22,7 @@ func TestRequestsInit(t *testing.T) {
 }
 
 func TestGetRequest(t *testing.T) {
-	google := RequestConfig{1, "http://google.com", "GET", nil, nil, nil, 200, 100, "1s", 0, "", 0, 0}
+	google := RequestConfig{Id: 1, Url: "http://google.com", RequestType: "GET", ResponseCode: 200, ResponseTime: 100, CheckEvery: "1s"}
 
 	err := PerformRequest(google, nil)
 	if err != nil {
@@ -26,7 +31,7 @@ func TestGetRequest(t *testing.T) {
 }
 
 func TestInvalidGetRequest(t *testing.T) {
-	invalid := RequestConfig{1, "http://localhost:64521", "GET", nil, nil, nil, 200, 100, "1s", 0, "", 0, 0}
+	invalid := RequestConfig{Id: 1, Url: "http://localhost:64521", RequestType: "GET", ResponseCode: 200, ResponseTime: 100, CheckEvery: "1s"}
 
 	err := PerformRequest(invalid, nil)
 
@@ -36,7 +41,7 @@ func TestInvalidGetRequest(t *testing.T) {
 }
 
 func TestInvalidPostRequest(t *testing.T) {
-	google := RequestConfig{1, "http://google.com", "POST", nil, nil, nil, 200, 100, "1s", 0, "", 0, 0}
+	google := RequestConfig{Id: 1, Url: "http://google.com", RequestType: "POST", ResponseCode: 200, ResponseTime: 100, CheckEvery: "1s"}
 
 	err := PerformRequest(google, nil)
 
@@ -44,3 +49,118 @@ func TestInvalidPostRequest(t *testing.T) {
 		t.Error("Invalid POST Request Succeded")
 	}
 }
+
+func TestValidateSchedule(t *testing.T) {
+	scheduled := RequestConfig{Url: "http://test.com", RequestType: "GET", ResponseTime: 100, Schedule: "*/5 9-17 * * MON-FRI", Timezone: "Europe/Berlin"}
+	assert.Nil(t, scheduled.Validate())
+	assert.NotNil(t, scheduled._schedule)
+
+	both := RequestConfig{Url: "http://test.com", RequestType: "GET", ResponseTime: 100, Schedule: "* * * * *", CheckEvery: "30s"}
+	assert.Error(t, both.Validate())
+
+	invalid := RequestConfig{Url: "http://test.com", RequestType: "GET", ResponseTime: 100, Schedule: "every minute"}
+	assert.Error(t, invalid.Validate())
+}
+
+func TestStartDelays(t *testing.T) {
+	reqs := []RequestConfig{
+		{_checkEvery: 30 * time.Second},
+		{_checkEvery: 30 * time.Second},
+		{_checkEvery: 60 * time.Second},
+		{_checkEvery: 30 * time.Second},
+	}
+
+	assert.Equal(t, []time.Duration{0, 0, 0, 0}, getStartDelays(reqs, ""))
+	assert.Equal(t, []time.Duration{0, 10 * time.Second, 0, 20 * time.Second}, getStartDelays(reqs, SpreadEven))
+
+	for i, delay := range getStartDelays(reqs, SpreadRandom) {
+		assert.True(t, delay >= 0 && delay < reqs[i]._checkEvery)
+	}
+
+	assert.Nil(t, ValidateSpread(""))
+	assert.Nil(t, ValidateSpread(SpreadEven))
+	assert.Error(t, ValidateSpread("uneven"))
+}
+
+func TestRetryTransientFailure(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	retried := RequestConfig{Id: 1, Url: server.URL, RequestType: "GET", ResponseCode: 200, ResponseTime: 100, Retries: 2}
+	assert.Nil(t, PerformRequest(retried, nil))
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	notRetried := RequestConfig{Id: 1, Url: server.URL, RequestType: "GET", ResponseCode: 200, ResponseTime: 100}
+	assert.Error(t, PerformRequest(notRetried, nil))
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryIntervalReleasesThrottle(t *testing.T) {
+	calls := make(chan int, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls <- 1
+		if len(calls) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	slots := make(chan int, 1)
+	slots <- 1
+	done := make(chan error)
+	retried := RequestConfig{Id: 1, Url: server.URL, RequestType: "GET", ResponseCode: 200, ResponseTime: 100, Retries: 1, _retryInterval: 300 * time.Millisecond}
+	go func() { done <- PerformRequest(retried, slots) }()
+
+	// the slot is free while waiting for the retry
+	time.Sleep(150 * time.Millisecond)
+	assert.Len(t, slots, 0)
+	assert.Nil(t, <-done)
+	assert.Len(t, calls, 2)
+	assert.Len(t, slots, 0)
+}
+
+func TestNoRetryForUnexpectedResponseCode(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	requestConfig := RequestConfig{Id: 1, Url: server.URL, RequestType: "GET", ResponseCode: 200, ResponseTime: 100, Retries: 2}
+	assert.Error(t, PerformRequest(requestConfig, nil))
+	assert.Equal(t, 1, calls)
+}
+
+func TestResultInfoForFailedRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("X-Version", "1.2")
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	requestConfig := RequestConfig{Id: 1, Url: server.URL, RequestType: "GET", ResponseCode: 200, ResponseTime: 100}
+	result := performHttpRequest(requestConfig)
+
+	assert.NotNil(t, result.errorInfo)
+	assert.True(t, result.errorInfo.ResponseTimeMs >= 20)
+	assert.Equal(t, "1.2", result.errorInfo.Headers["X-Version"])
+
+	resultInfo := result.resultInfo(requestConfig, 2)
+	assert.False(t, resultInfo.Success)
+	assert.Equal(t, 2, resultInfo.Attempt)
+	assert.Equal(t, http.StatusInternalServerError, resultInfo.ResponseCode)
+	assert.Equal(t, result.errorInfo.ResponseTimeMs, resultInfo.ResponseTimeMs)
+	assert.Equal(t, "unexpected_status", resultInfo.Category)
+	assert.Equal(t, "1.2", resultInfo.Headers["X-Version"])
+}
@@ -0,0 +1,210 @@
+package requests
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"net/url"
+	"statusok/database"
+	"statusok/model"
+	"time"
+)
+
+// default ports of the protocols of session checks, schemes ending with s use TLS from the start
+var defaultPorts = map[string]string{
+	"smtp":     "25",
+	"smtps":    "465",
+	"imap":     "143",
+	"imaps":    "993",
+	"pop3":     "110",
+	"pop3s":    "995",
+	"redis":    "6379",
+	"rediss":   "6380",
+	"postgres": "5432",
+	"mysql":    "3306",
+}
+
+// replyError is a negative or unexpected reply of a server
+type replyError struct {
+	Code int
+	Msg  string
+}
+
+func (e *replyError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("Got reply %d %s", e.Code, e.Msg)
+	}
+	return e.Msg
+}
+
+// queryError is an error returned by a database for the query of a check
+type queryError struct {
+	Msg string
+}
+
+func (e *queryError) Error() string {
+	return e.Msg
+}
+
+// resultError is a query result not matching the assertion of the check
+type resultError struct {
+	Msg string
+}
+
+func (e *resultError) Error() string {
+	return e.Msg
+}
+
+// checkSession is the connection of a check speaking a protocol other than http over TCP.
+// The time of each stage is saved as metric <stage>Ms.
+type checkSession struct {
+	conn      net.Conn
+	tlsConfig *tls.Config
+	tlsState  *tls.ConnectionState
+	stageName string
+	last      time.Time
+	metrics   map[string]float64
+}
+
+// Saves the time since the last stage as metric <stage>Ms and starts the next stage.
+// Without next stage the check keeps the name of the last one for later errors e.g. of the result.
+func (session *checkSession) done(next string) {
+	now := time.Now()
+	session.metrics[session.stageName+"Ms"] = float64(now.Sub(session.last).Milliseconds())
+	session.last = now
+	if len(next) != 0 {
+		session.stageName = next
+	}
+}
+
+// Performs the TLS handshake on the connection, right after connecting for implicit TLS or after
+// the protocol negotiated it e.g. with STARTTLS
+func (session *checkSession) startTLS() error {
+	tlsConn := tls.Client(session.conn, session.tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	state := tlsConn.ConnectionState()
+	session.conn, session.tlsState = tlsConn, &state
+	return nil
+}
+
+// connects to the server of the url, with TLS for schemes of the check type followed by s, and runs
+// the protocol. Errors are reported with the stage they occurred in.
+func performSessionCheck(requestConfig RequestConfig, run func(session *checkSession) error) attemptResult {
+	metrics := make(map[string]float64)
+	failure := func(err error, reason error, category string, status int, elapsed time.Duration, transient bool) attemptResult {
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:             requestConfig.Id,
+				Url:            requestConfig.Url,
+				RequestType:    requestConfig.RequestType,
+				ResponseCode:   status,
+				ResponseTimeMs: elapsed.Milliseconds(),
+				Reason:         reason,
+				Category:       category,
+				OtherInfo:      err.Error(),
+				Metrics:        metrics,
+			},
+			err:       err,
+			transient: transient,
+			elapsed:   elapsed,
+		}
+	}
+
+	target, err := url.Parse(requestConfig.Url)
+	if err != nil {
+		return failure(err, database.ErrCreateRequest, model.CategoryInvalidRequest, 0, 0, false)
+	}
+	implicitTLS := target.Scheme == requestConfig.Type+"s"
+	address := target.Host
+	if len(target.Port()) == 0 {
+		address = net.JoinHostPort(target.Hostname(), defaultPorts[target.Scheme])
+	}
+
+	dialer := &net.Dialer{Timeout: requestConfig._timeout}
+	if len(requestConfig.SourceAddress) != 0 {
+		ip := net.ParseIP(requestConfig.SourceAddress)
+		if ip == nil {
+			err := fmt.Errorf("Invalid sourceAddress %s", requestConfig.SourceAddress)
+			return failure(err, database.ErrCreateRequest, model.CategoryInvalidRequest, 0, 0, false)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	tlsConfig := &tls.Config{}
+	if requestConfig.TLS != nil {
+		if tlsConfig, err = requestConfig.TLS.TLSConfig(); err != nil {
+			return failure(err, database.ErrCreateRequest, model.CategoryInvalidRequest, 0, 0, false)
+		}
+	}
+	if len(tlsConfig.ServerName) == 0 {
+		tlsConfig.ServerName = target.Hostname()
+	}
+
+	start := time.Now()
+	session := &checkSession{tlsConfig: tlsConfig, stageName: "connect", last: start, metrics: metrics}
+	conn, err := dialer.Dial("tcp", address)
+	if err == nil {
+		session.conn = conn
+		defer func() { session.conn.Close() }()
+		if requestConfig._timeout > 0 {
+			conn.SetDeadline(start.Add(requestConfig._timeout))
+		}
+
+		if implicitTLS {
+			session.done("tls")
+			err = session.startTLS()
+		}
+	}
+	if err == nil {
+		err = run(session)
+	}
+	elapsed := time.Since(start)
+
+	if err != nil {
+		stage := session.stageName
+		err = fmt.Errorf("Stage %s failed: %w", stage, err)
+
+		var replyErr *replyError
+		var queryErr *queryError
+		var resultErr *resultError
+		var protocolErr textproto.ProtocolError
+		switch {
+		case errors.As(err, &replyErr) && stage == "auth":
+			return failure(err, database.ErrAuth, model.CategoryAuthFailed, replyErr.Code, elapsed, false)
+		case errors.As(err, &replyErr):
+			// 4xx replies of smtp servers are temporary
+			return failure(err, err, model.CategoryUnexpectedStatus, replyErr.Code, elapsed, replyErr.Code >= 400 && replyErr.Code < 500)
+		case errors.As(err, &queryErr):
+			return failure(err, database.ErrQuery, model.CategoryQueryFailed, 0, elapsed, false)
+		case errors.As(err, &resultErr):
+			return failure(err, err, model.CategoryAssertionFailed, 0, elapsed, false)
+		case errors.As(err, &protocolErr):
+			return failure(err, database.ErrReadResponse, model.CategoryUnexpectedStatus, 0, elapsed, false)
+		}
+		category := classifyError(err)
+		reason := database.ErrDoRequest
+		if category == model.CategoryTimeout {
+			reason = database.ErrTimeout
+		}
+		return failure(err, reason, category, 0, elapsed, true)
+	}
+
+	if err := checkCertificateExpiry(requestConfig, session.tlsState, metrics); err != nil {
+		return failure(err, database.ErrCertExpiry, model.CategoryCertificateExpiry, 0, elapsed, false)
+	}
+
+	return attemptResult{
+		requestInfo: model.RequestInfo{
+			Id:                   requestConfig.Id,
+			Url:                  requestConfig.Url,
+			RequestType:          requestConfig.RequestType,
+			ResponseTimeMs:       elapsed.Milliseconds(),
+			ExpectedResponseTime: requestConfig.ResponseTime,
+			Metrics:              metrics,
+		},
+		elapsed: elapsed,
+	}
+}
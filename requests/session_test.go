@@ -0,0 +1,47 @@
+package requests
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fake server answering each connection with serve. Returns the address and the
+// CA file of the certificate used for TLS.
+func sessionServer(t *testing.T, serve func(conn net.Conn, tlsConfig *tls.Config)) (string, string, func()) {
+	certServer := httptest.NewTLSServer(nil)
+	tlsConfig := &tls.Config{Certificates: certServer.TLS.Certificates}
+
+	dir, err := ioutil.TempDir("", "session")
+	assert.Nil(t, err)
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.Nil(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certServer.Certificate().Raw}), 0600))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serve(conn, tlsConfig)
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), caFile, func() {
+		listener.Close()
+		certServer.Close()
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,93 @@
+package requests
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type codeRange struct {
+	from, to int
+}
+
+// Expected response codes of a request given as codes, classes or ranges,
+// e.g. "200", "2xx", "200-299". Entries starting with ! exclude codes.
+type responseCodeMatcher struct {
+	include []codeRange
+	exclude []codeRange
+	spec    []string
+}
+
+// Parse response codes like "204", "2xx", "200-299" or "!5xx"
+func parseResponseCodes(codes []string) (*responseCodeMatcher, error) {
+	matcher := &responseCodeMatcher{}
+
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		exclude := strings.HasPrefix(code, "!")
+
+		r, err := parseCodeRange(strings.TrimPrefix(code, "!"))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid response code %s: %s", code, err)
+		}
+		if exclude {
+			matcher.exclude = append(matcher.exclude, r)
+		} else {
+			matcher.include = append(matcher.include, r)
+		}
+		matcher.spec = append(matcher.spec, code)
+	}
+
+	if len(matcher.spec) == 0 {
+		return nil, fmt.Errorf("ResponseCodes cannot be empty")
+	}
+	return matcher, nil
+}
+
+func parseCodeRange(code string) (codeRange, error) {
+	if len(code) == 3 && strings.HasSuffix(strings.ToLower(code), "xx") {
+		class, err := strconv.Atoi(code[:1])
+		if err != nil || class < 1 || class > 5 {
+			return codeRange{}, fmt.Errorf("unknown class")
+		}
+		return codeRange{class * 100, class*100 + 99}, nil
+	}
+
+	if bounds := strings.SplitN(code, "-", 2); len(bounds) == 2 {
+		from, fromErr := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		to, toErr := strconv.Atoi(strings.TrimSpace(bounds[1]))
+		if fromErr != nil || toErr != nil || from > to {
+			return codeRange{}, fmt.Errorf("invalid range")
+		}
+		return codeRange{from, to}, nil
+	}
+
+	single, err := strconv.Atoi(code)
+	if err != nil {
+		return codeRange{}, fmt.Errorf("not a number")
+	}
+	return codeRange{single, single}, nil
+}
+
+// Tells whether the response code is one of the expected ones.
+// Without included codes all codes not excluded are expected.
+func (matcher *responseCodeMatcher) matches(code int) bool {
+	for _, r := range matcher.exclude {
+		if code >= r.from && code <= r.to {
+			return false
+		}
+	}
+	if len(matcher.include) == 0 {
+		return true
+	}
+	for _, r := range matcher.include {
+		if code >= r.from && code <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+func (matcher *responseCodeMatcher) String() string {
+	return strings.Join(matcher.spec, ", ")
+}
@@ -0,0 +1,65 @@
+package requests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResponseCodes(t *testing.T) {
+	tests := []struct {
+		codes      []string
+		matches    []int
+		notMatches []int
+	}{
+		{[]string{"200", "204"}, []int{200, 204}, []int{201, 500}},
+		{[]string{"2xx", "3xx"}, []int{200, 299, 301}, []int{199, 404}},
+		{[]string{"200-299"}, []int{200, 250, 299}, []int{300}},
+		{[]string{"!5xx"}, []int{200, 404}, []int{500, 503}},
+		{[]string{"2xx", "!204"}, []int{200, 201}, []int{204, 302}},
+	}
+
+	for _, test := range tests {
+		matcher, err := parseResponseCodes(test.codes)
+		assert.Nil(t, err, test.codes)
+		for _, code := range test.matches {
+			assert.True(t, matcher.matches(code), "%v should match %d", test.codes, code)
+		}
+		for _, code := range test.notMatches {
+			assert.False(t, matcher.matches(code), "%v should not match %d", test.codes, code)
+		}
+	}
+
+	for _, invalid := range [][]string{{}, {"abc"}, {"6xx"}, {"299-200"}, {"!"}} {
+		_, err := parseResponseCodes(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestValidateResponseCodes(t *testing.T) {
+	both := RequestConfig{Url: "http://test.com", RequestType: "GET", ResponseTime: 100, ResponseCode: 200, ResponseCodes: []string{"2xx"}}
+	assert.Error(t, both.Validate())
+
+	codes := RequestConfig{Url: "http://test.com", RequestType: "GET", ResponseTime: 100, ResponseCodes: []string{"2xx", "!204"}}
+	assert.Nil(t, codes.Validate())
+	assert.Equal(t, "2xx, !204", codes.expectedResponseCodes().String())
+
+	code := RequestConfig{Url: "http://test.com", RequestType: "GET", ResponseTime: 100}
+	assert.Nil(t, code.Validate())
+	assert.Equal(t, "200", code.expectedResponseCodes().String())
+}
+
+func TestUnexpectedResponseCodeReportsExpectedCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	accepted := RequestConfig{Id: 1, Url: server.URL, RequestType: "GET", ResponseTime: 100, ResponseCodes: []string{"200", "204"}}
+	assert.Nil(t, PerformRequest(accepted, nil))
+
+	rejected := RequestConfig{Id: 1, Url: server.URL, RequestType: "GET", ResponseTime: 100, ResponseCodes: []string{"2xx", "!204"}}
+	assert.EqualError(t, PerformRequest(rejected, nil), "Got Response code 204. Expected Response Code 2xx, !204 ")
+}
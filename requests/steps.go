@@ -0,0 +1,314 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"statusok/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// variables are referenced as ${name} in url, headers and body of later steps
+var variablePattern = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// Step is a single request of a transaction check
+type Step struct {
+	Name             string            `json:"name"`
+	Url              string            `json:"url"`
+	RequestType      string            `json:"requestType"`
+	Headers          map[string]string `json:"headers"`
+	FormParams       map[string]string `json:"formParams"`
+	Body             string            `json:"body"`
+	JsonBody         json.RawMessage   `json:"jsonBody"`
+	UrlParams        map[string]string `json:"urlParams"`
+	ResponseCode     int               `json:"responseCode"`
+	ResponseCodes    []string          `json:"responseCodes"`
+	HeaderAssertions []HeaderAssertion `json:"headerAssertions"`
+	Capture          []Capture         `json:"capture"`
+}
+
+// Capture stores a value of the response in a variable. Exactly one of
+// JsonPath, Header, Regex (first group or whole match in the body) or Cookie has to be given.
+type Capture struct {
+	Variable string `json:"variable"`
+	JsonPath string `json:"jsonPath"`
+	Header   string `json:"header"`
+	Regex    string `json:"regex"`
+	Cookie   string `json:"cookie"`
+}
+
+// Name of the step used in errors, its position if it has no name
+func (step Step) label(index int) string {
+	if len(step.Name) != 0 {
+		return step.Name
+	}
+	return "#" + strconv.Itoa(index+1)
+}
+
+// check whether all step fields are valid
+func (step *Step) Validate() error {
+	if len(step.Url) == 0 {
+		return errors.New("Url of step cannot be empty")
+	}
+	if len(step.RequestType) == 0 {
+		step.RequestType = http.MethodGet
+	}
+	if len(step.ResponseCodes) != 0 {
+		if step.ResponseCode != 0 {
+			return errors.New("Either ResponseCode or ResponseCodes can be given")
+		}
+		if _, err := parseResponseCodes(step.ResponseCodes); err != nil {
+			return err
+		}
+	}
+
+	count := 0
+	for _, given := range []bool{len(step.FormParams) != 0, len(step.Body) != 0, len(step.JsonBody) != 0} {
+		if given {
+			count++
+		}
+	}
+	if count > 1 {
+		return errors.New("Only one of formParams, body or jsonBody can be given")
+	}
+
+	for _, assertion := range step.HeaderAssertions {
+		if err := assertion.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, capture := range step.Capture {
+		if err := capture.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check whether the capture is valid
+func (capture Capture) Validate() error {
+	if len(capture.Variable) == 0 {
+		return errors.New("Variable of capture cannot be empty")
+	}
+
+	count := 0
+	for _, given := range []bool{len(capture.JsonPath) != 0, len(capture.Header) != 0, len(capture.Regex) != 0, len(capture.Cookie) != 0} {
+		if given {
+			count++
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("Capture of %s needs exactly one of jsonPath, header, regex or cookie", capture.Variable)
+	}
+
+	if len(capture.JsonPath) != 0 {
+		if _, err := parseJsonPath(capture.JsonPath); err != nil {
+			return err
+		}
+	}
+	if len(capture.Regex) != 0 {
+		if _, err := regexp.Compile(capture.Regex); err != nil {
+			return fmt.Errorf("Capture of %s has an invalid regular expression: %s", capture.Variable, err)
+		}
+	}
+	return nil
+}
+
+// Returns the captured value from the result of a step
+func (capture Capture) value(result attemptResult) (string, error) {
+	switch {
+	case len(capture.JsonPath) != 0:
+		return evalJsonPath(result.body, capture.JsonPath)
+
+	case len(capture.Header) != 0:
+		if values := result.response.Header.Values(capture.Header); len(values) != 0 {
+			return values[0], nil
+		}
+		return "", fmt.Errorf("Header %s is missing", capture.Header)
+
+	case len(capture.Regex) != 0:
+		re, err := regexp.Compile(capture.Regex)
+		if err != nil {
+			return "", err
+		}
+		match := re.FindSubmatch(result.body)
+		if match == nil {
+			return "", fmt.Errorf("Response does not match %s", capture.Regex)
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+
+	case len(capture.Cookie) != 0:
+		for _, cookie := range result.response.Cookies() {
+			if cookie.Name == capture.Cookie {
+				return cookie.Value, nil
+			}
+		}
+		return "", fmt.Errorf("Cookie %s is not set", capture.Cookie)
+	}
+	return "", nil
+}
+
+// Replaces ${name} with the value of the variable, unknown variables are kept
+func substituteVariables(s string, variables map[string]string) string {
+	if len(variables) == 0 {
+		return s
+	}
+	return variablePattern.ReplaceAllStringFunc(s, func(reference string) string {
+		if value, ok := variables[variablePattern.FindStringSubmatch(reference)[1]]; ok {
+			return value
+		}
+		return reference
+	})
+}
+
+// Replaces ${name} with the value of the variable escaped for a json string, unknown variables are kept
+func substituteJsonVariables(s string, variables map[string]string) string {
+	escaped := make(map[string]string, len(variables))
+	for name, value := range variables {
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(value)
+		// without the quotes and the newline added by the encoder
+		encoded := buffer.String()
+		escaped[name] = encoded[1 : len(encoded)-2]
+	}
+	return substituteVariables(s, escaped)
+}
+
+func substituteMap(values map[string]string, variables map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	substituted := make(map[string]string, len(values))
+	for key, value := range values {
+		substituted[key] = substituteVariables(value, variables)
+	}
+	return substituted
+}
+
+// Creates the request of a step with the variables captured so far
+func (step Step) requestConfig(check RequestConfig, variables map[string]string, jar *cookiejar.Jar) RequestConfig {
+	requestConfig := RequestConfig{
+		Id:               check.Id,
+		Url:              substituteVariables(step.Url, variables),
+		RequestType:      step.RequestType,
+		Headers:          substituteMap(step.Headers, variables),
+		FormParams:       substituteMap(step.FormParams, variables),
+		Body:             substituteVariables(step.Body, variables),
+		UrlParams:        substituteMap(step.UrlParams, variables),
+		ResponseCode:     step.ResponseCode,
+		ResponseCodes:    step.ResponseCodes,
+		HeaderAssertions: step.HeaderAssertions,
+		ResponseTime:     check.ResponseTime,
+		Auth:             check.Auth,
+		TLS:              check.TLS,
+		Proxy:            check.Proxy,
+		SourceAddress:    check.SourceAddress,
+		_transport:       check._transport,
+		_timeout:         check._timeout,
+		_jar:             jar,
+	}
+	if len(step.JsonBody) != 0 {
+		requestConfig.JsonBody = json.RawMessage(substituteJsonVariables(string(step.JsonBody), variables))
+	}
+	return requestConfig
+}
+
+// performs all steps of a transaction check in order, sharing cookies between them.
+// The check fails with the first failing step and is timed as a whole.
+func performSteps(requestConfig RequestConfig) attemptResult {
+	jar, _ := cookiejar.New(nil)
+	variables := make(map[string]string)
+	var elapsed time.Duration
+	var result attemptResult
+
+	for i, step := range requestConfig.Steps {
+		result = performHttpRequest(step.requestConfig(requestConfig, variables, jar))
+		elapsed += result.elapsed
+
+		if result.errorInfo == nil {
+			for _, capture := range step.Capture {
+				value, err := capture.value(result)
+				if err != nil {
+					err = fmt.Errorf("Capture of %s failed: %s", capture.Variable, err)
+					result.errorInfo = &model.ErrorInfo{
+						Id:           requestConfig.Id,
+						Url:          result.requestInfo.Url,
+						RequestType:  result.requestInfo.RequestType,
+						ResponseCode: result.requestInfo.ResponseCode,
+						Headers:      result.headers,
+						ResponseBody: string(result.body),
+						Reason:       err,
+						Category:     model.CategoryAssertionFailed,
+					}
+					result.err = err
+					break
+				}
+				variables[capture.Variable] = value
+			}
+		}
+
+		if result.errorInfo != nil {
+			// identify the failing step, keep the url of the check
+			result.errorInfo.Step = step.label(i)
+			result.errorInfo.OtherInfo = fmt.Sprintf("Step %s (%d of %d) %s %s failed. %s", step.label(i), i+1, len(requestConfig.Steps),
+				result.errorInfo.RequestType, result.errorInfo.Url, result.errorInfo.OtherInfo)
+			result.errorInfo.Url = requestConfig.Url
+			result.errorInfo.RequestType = requestConfig.RequestType
+			result.errorInfo.ResponseTimeMs = elapsed.Milliseconds()
+			result.err = fmt.Errorf("Step %s failed: %s", step.label(i), result.err)
+			result.elapsed = elapsed
+			return result
+		}
+	}
+
+	return attemptResult{
+		requestInfo: model.RequestInfo{
+			Id:                   requestConfig.Id,
+			Url:                  requestConfig.Url,
+			RequestType:          requestConfig.RequestType,
+			ResponseCode:         result.requestInfo.ResponseCode,
+			ResponseTimeMs:       elapsed.Milliseconds(),
+			ExpectedResponseTime: requestConfig.ResponseTime,
+		},
+		elapsed: elapsed,
+		headers: result.headers,
+	}
+}
+
+// check the steps of a transaction check, the first step is used as url of the check if not given
+func (requestConfig *RequestConfig) validateSteps() error {
+	if len(requestConfig.Steps) == 0 {
+		return errors.New("Steps cannot be empty for a check of type steps")
+	}
+	for i := range requestConfig.Steps {
+		if err := requestConfig.Steps[i].Validate(); err != nil {
+			return fmt.Errorf("Step %s: %s", requestConfig.Steps[i].label(i), err)
+		}
+		// auth of the check is used for every step
+		if requestConfig.Auth != nil && len(requestConfig.Steps[i].Headers[Authorization]) != 0 {
+			return fmt.Errorf("Step %s: Either Auth of the check or an Authorization header can be given", requestConfig.Steps[i].label(i))
+		}
+	}
+	if len(requestConfig.Url) == 0 {
+		requestConfig.Url = requestConfig.Steps[0].Url
+	}
+	if len(requestConfig.RequestType) == 0 {
+		requestConfig.RequestType = strings.ToUpper(TypeSteps)
+	}
+	if _, err := url.Parse(requestConfig.Url); err != nil {
+		return errors.New("Invalid Url")
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package requests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"statusok/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalJsonPath(t *testing.T) {
+	document := []byte(`{"access_token":"abc","data":{"items":[{"id":12345678901234567},{"id":2}]},"ok":true}`)
+
+	tests := map[string]string{
+		"$.access_token":      "abc",
+		"$['access_token']":   "abc",
+		"$.data.items[0].id":  "12345678901234567",
+		"$.data.items[-1].id": "2",
+		"$.ok":                "true",
+		"$.data.items[1]":     `{"id":2}`,
+	}
+	for path, expected := range tests {
+		value, err := evalJsonPath(document, path)
+		assert.Nil(t, err, path)
+		assert.Equal(t, expected, value, path)
+	}
+
+	for _, path := range []string{"$.missing", "$.data.items[2]", "$.access_token.id", "data", "$.data[", "$.data[x]"} {
+		_, err := evalJsonPath(document, path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestSubstituteVariables(t *testing.T) {
+	variables := map[string]string{"token": "abc", "id": "42"}
+	assert.Equal(t, "Bearer abc", substituteVariables("Bearer ${token}", variables))
+	assert.Equal(t, "/records/42?missing=${other}", substituteVariables("/records/${id}?missing=${other}", variables))
+
+	// values are escaped in json bodies
+	variables["name"] = "a \"quoted\" \\ <name>\n"
+	body := substituteJsonVariables(`{"id":${id},"name":"${name}"}`, variables)
+	assert.Equal(t, `{"id":42,"name":"a \"quoted\" \\ <name>\n"}`, body)
+	var decoded map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(body), &decoded))
+	assert.Equal(t, variables["name"], decoded["name"])
+}
+
+func TestValidateSteps(t *testing.T) {
+	valid := RequestConfig{Type: TypeSteps, ResponseTime: 100, Steps: []Step{
+		{Name: "login", Url: "http://test.com/login", RequestType: "POST", Capture: []Capture{{Variable: "token", JsonPath: "$.token"}}},
+		{Url: "http://test.com/api"},
+	}}
+	assert.Nil(t, valid.Validate())
+	assert.Equal(t, "http://test.com/login", valid.Url)
+	assert.Equal(t, "STEPS", valid.RequestType)
+	assert.Equal(t, http.MethodGet, valid.Steps[1].RequestType)
+
+	invalid := []RequestConfig{
+		{Type: TypeSteps, ResponseTime: 100},
+		{Type: "unknown", Url: "http://test.com", RequestType: "GET", ResponseTime: 100},
+		{Type: TypeSteps, ResponseTime: 100, Steps: []Step{{}}},
+		{Type: TypeSteps, ResponseTime: 100, Steps: []Step{{Url: "http://test.com", Capture: []Capture{{Variable: "token"}}}}},
+		{Type: TypeSteps, ResponseTime: 100, Steps: []Step{{Url: "http://test.com", Capture: []Capture{{Variable: "token", Header: "X-Token", Cookie: "session"}}}}},
+		{Type: TypeSteps, ResponseTime: 100, Steps: []Step{{Url: "http://test.com", Capture: []Capture{{Variable: "token", Regex: "("}}}}},
+		{Type: TypeSteps, ResponseTime: 100, Auth: &AuthConfig{Type: AuthBasic, Username: "monitor"},
+			Steps: []Step{{Url: "http://test.com", Headers: map[string]string{"Authorization": "Bearer abc"}}}},
+	}
+	for _, requestConfig := range invalid {
+		assert.Error(t, requestConfig.Validate())
+	}
+}
+
+func TestPerformSteps(t *testing.T) {
+	deleted := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+			w.Header().Set("X-Request-Id", "r1")
+			w.Write([]byte(`{"token":"abc"}`))
+		case "/records":
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`<record id="42"/>`))
+		case "/records/42":
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "s1" || r.Header.Get("X-Request-Id") != "r1" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			deleted = r.URL.Path
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	steps := []Step{
+		{Name: "login", Url: server.URL + "/login", RequestType: "POST", JsonBody: []byte(`{"user":"monitor"}`),
+			Capture: []Capture{{Variable: "token", JsonPath: "$.token"}, {Variable: "requestId", Header: "X-Request-Id"}, {Variable: "session", Cookie: "session"}}},
+		{Name: "create", Url: server.URL + "/records", RequestType: "POST", Headers: map[string]string{"Authorization": "Bearer ${token}"}, ResponseCode: 201,
+			Capture: []Capture{{Variable: "id", Regex: `id="(\d+)"`}}},
+		{Name: "delete", Url: server.URL + "/records/${id}", RequestType: "DELETE", Headers: map[string]string{"X-Request-Id": "${requestId}"}, ResponseCodes: []string{"2xx"}},
+	}
+
+	requestConfig := RequestConfig{Id: 1, Type: TypeSteps, ResponseTime: 100, Steps: steps}
+	assert.Nil(t, requestConfig.Validate())
+
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.errorInfo)
+	assert.Equal(t, "/records/42", deleted)
+	assert.Equal(t, http.StatusNoContent, result.requestInfo.ResponseCode)
+	assert.Equal(t, server.URL+"/login", result.requestInfo.Url)
+
+	// the token is not captured, so creating the record fails
+	steps[0].Capture = nil
+	requestConfig = RequestConfig{Id: 1, Type: TypeSteps, ResponseTime: 100, Steps: steps}
+	assert.Nil(t, requestConfig.Validate())
+
+	result = performAttempt(requestConfig)
+	assert.NotNil(t, result.errorInfo)
+	assert.Equal(t, "create", result.errorInfo.Step)
+	assert.Equal(t, server.URL+"/login", result.errorInfo.Url)
+	assert.Equal(t, http.StatusUnauthorized, result.errorInfo.ResponseCode)
+	assert.Equal(t, model.CategoryUnexpectedStatus, result.errorInfo.Category)
+	assert.Contains(t, result.errorInfo.OtherInfo, "Step create (2 of 3)")
+	assert.EqualError(t, result.err, "Step create failed: Got Response code 401. Expected Response Code 201 ")
+}
+
+func TestStepsAuth(t *testing.T) {
+	var users []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		users = append(users, user)
+	}))
+	defer server.Close()
+
+	requestConfig := RequestConfig{Id: 1, Type: TypeSteps, ResponseTime: 100, Auth: &AuthConfig{Type: AuthBasic, Username: "monitor", Password: "secret"},
+		Steps: []Step{{Url: server.URL + "/login"}, {Url: server.URL + "/api"}}}
+	assert.Nil(t, requestConfig.Validate())
+
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.errorInfo)
+	assert.Equal(t, []string{"monitor", "monitor"}, users)
+}
+
+func TestStepCaptureFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":"no token"}`))
+	}))
+	defer server.Close()
+
+	requestConfig := RequestConfig{Id: 1, Type: TypeSteps, ResponseTime: 100, Steps: []Step{
+		{Url: server.URL, Capture: []Capture{{Variable: "token", JsonPath: "$.token"}}},
+	}}
+	assert.Nil(t, requestConfig.Validate())
+
+	result := performAttempt(requestConfig)
+	assert.NotNil(t, result.errorInfo)
+	assert.Equal(t, "#1", result.errorInfo.Step)
+	assert.Equal(t, model.CategoryAssertionFailed, result.errorInfo.Category)
+	assert.EqualError(t, result.err, "Step #1 failed: Capture of token failed: $.token not found, missing token")
+}
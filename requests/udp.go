@@ -0,0 +1,320 @@
+package requests
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"statusok/database"
+	"statusok/model"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultNtpPort = "123"
+	maxUdpMessage  = 1 << 16
+
+	// seconds from the NTP epoch 1900 to the unix epoch 1970
+	ntpEpochOffset = 2208988800
+	ntpPacketSize  = 48
+	ntpLeapAlarm   = 3
+	ntpModeServer  = 4
+)
+
+// UdpConfig is the exchange of a udp check. Send or SendHex is sent as datagram, the response
+// has to match the regular expression Expect.
+type UdpConfig struct {
+	Send    string `json:"send"`
+	SendHex string `json:"sendHex"`
+	Expect  string `json:"expect"`
+}
+
+// NtpConfig are the thresholds of a ntp check. The check fails if the offset of the local clock
+// to the server exceeds MaxOffset or the stratum of the server exceeds MaxStratum.
+type NtpConfig struct {
+	MaxOffset  string        `json:"maxOffset"`
+	_maxOffset time.Duration `json:"-"`
+	MaxStratum *int          `json:"maxStratum"`
+}
+
+// check the url and payload of a udp check, udp://host:port
+func (requestConfig *RequestConfig) validateUdp() error {
+	target, err := url.Parse(requestConfig.Url)
+	if err != nil || len(target.Host) == 0 {
+		return errors.New("Invalid Url")
+	}
+	if target.Scheme != "udp" || len(target.Port()) == 0 {
+		return fmt.Errorf("Invalid Url %s, use udp://host:port", requestConfig.Url)
+	}
+	if len(requestConfig.Proxy) != 0 {
+		return errors.New("Proxy cannot be given for a check of type udp")
+	}
+	if requestConfig.Udp == nil || len(requestConfig.Udp.Send) == 0 && len(requestConfig.Udp.SendHex) == 0 {
+		return errors.New("Send or sendHex cannot be empty for a check of type udp")
+	}
+	if len(requestConfig.Udp.Send) != 0 && len(requestConfig.Udp.SendHex) != 0 {
+		return errors.New("Either send or sendHex can be given")
+	}
+	if _, err := hex.DecodeString(requestConfig.Udp.SendHex); err != nil {
+		return fmt.Errorf("SendHex is invalid: %s", err)
+	}
+	if len(requestConfig.Udp.Expect) != 0 {
+		if _, err := regexp.Compile(requestConfig.Udp.Expect); err != nil {
+			return fmt.Errorf("Expect has an invalid regular expression: %s", err)
+		}
+	}
+	if len(requestConfig.RequestType) == 0 {
+		requestConfig.RequestType = strings.ToUpper(TypeUdp)
+	}
+	return nil
+}
+
+// check the url and thresholds of a ntp check, ntp://host or ntp://host:port
+func (requestConfig *RequestConfig) validateNtp() error {
+	target, err := url.Parse(requestConfig.Url)
+	if err != nil || len(target.Host) == 0 {
+		return errors.New("Invalid Url")
+	}
+	if target.Scheme != "ntp" {
+		return fmt.Errorf("Invalid Url %s, use ntp://host:port", requestConfig.Url)
+	}
+	if len(requestConfig.Proxy) != 0 {
+		return errors.New("Proxy cannot be given for a check of type ntp")
+	}
+	if requestConfig.Ntp != nil {
+		if len(requestConfig.Ntp.MaxOffset) != 0 {
+			if requestConfig.Ntp._maxOffset, err = time.ParseDuration(requestConfig.Ntp.MaxOffset); err != nil {
+				return fmt.Errorf("MaxOffset format is invalid %s", err)
+			}
+			if requestConfig.Ntp._maxOffset <= 0 {
+				return errors.New("MaxOffset has to be positive")
+			}
+		}
+		if maxStratum := requestConfig.Ntp.MaxStratum; maxStratum != nil && (*maxStratum < 1 || *maxStratum > 15) {
+			return errors.New("MaxStratum has to be between 1 and 15")
+		}
+	}
+	if len(requestConfig.RequestType) == 0 {
+		requestConfig.RequestType = strings.ToUpper(TypeNtp)
+	}
+	return nil
+}
+
+// Connects a udp socket to the address, from sourceAddress if given
+func dialUdp(requestConfig RequestConfig, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: requestConfig._timeout}
+	if len(requestConfig.SourceAddress) != 0 {
+		ip := net.ParseIP(requestConfig.SourceAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid sourceAddress %s", requestConfig.SourceAddress)
+		}
+		dialer.LocalAddr = &net.UDPAddr{IP: ip}
+	}
+	return dialer.Dial("udp", address)
+}
+
+// Failure of a udp or ntp check
+func udpFailure(requestConfig RequestConfig, err error, reason error, category string, elapsed time.Duration, transient bool, metrics map[string]float64) attemptResult {
+	return attemptResult{
+		errorInfo: &model.ErrorInfo{
+			Id:             requestConfig.Id,
+			Url:            requestConfig.Url,
+			RequestType:    requestConfig.RequestType,
+			ResponseTimeMs: elapsed.Milliseconds(),
+			Reason:         reason,
+			Category:       category,
+			OtherInfo:      err.Error(),
+			Metrics:        metrics,
+		},
+		err:       err,
+		transient: transient,
+		elapsed:   elapsed,
+	}
+}
+
+// Failure of sending or receiving, a missing response is a timeout
+func udpTransportFailure(requestConfig RequestConfig, err error, elapsed time.Duration, metrics map[string]float64) attemptResult {
+	category := classifyError(err)
+	reason := database.ErrDoRequest
+	if category == model.CategoryTimeout {
+		reason = database.ErrTimeout
+	}
+	return udpFailure(requestConfig, err, reason, category, elapsed, true, metrics)
+}
+
+// sends the payload as datagram and waits for the response within the timeout.
+// The round trip time is saved as metric roundTripMs.
+func performUdpCheck(requestConfig RequestConfig) attemptResult {
+	metrics := make(map[string]float64)
+	target, _ := url.Parse(requestConfig.Url)
+	payload := []byte(requestConfig.Udp.Send)
+	if len(requestConfig.Udp.SendHex) != 0 {
+		payload, _ = hex.DecodeString(requestConfig.Udp.SendHex)
+	}
+
+	start := time.Now()
+	conn, err := dialUdp(requestConfig, target.Host)
+	if err != nil {
+		return udpTransportFailure(requestConfig, err, time.Since(start), metrics)
+	}
+	defer conn.Close()
+	if requestConfig._timeout > 0 {
+		conn.SetDeadline(start.Add(requestConfig._timeout))
+	}
+
+	if _, err := conn.Write(payload); err != nil {
+		return udpTransportFailure(requestConfig, err, time.Since(start), metrics)
+	}
+	response := make([]byte, maxUdpMessage)
+	n, err := conn.Read(response)
+	elapsed := time.Since(start)
+	if err != nil {
+		return udpTransportFailure(requestConfig, err, elapsed, metrics)
+	}
+	response = response[:n]
+	metrics["roundTripMs"] = float64(elapsed.Milliseconds())
+
+	if len(requestConfig.Udp.Expect) != 0 {
+		re, err := regexp.Compile(requestConfig.Udp.Expect)
+		if err != nil {
+			return udpFailure(requestConfig, err, database.ErrCreateRequest, model.CategoryInvalidRequest, elapsed, false, metrics)
+		}
+		if !re.Match(response) {
+			err := fmt.Errorf("Response %q does not match %q", response, requestConfig.Udp.Expect)
+			result := udpFailure(requestConfig, err, err, model.CategoryAssertionFailed, elapsed, false, metrics)
+			result.errorInfo.ResponseBody = string(response)
+			return result
+		}
+	}
+
+	return attemptResult{
+		requestInfo: model.RequestInfo{
+			Id:                   requestConfig.Id,
+			Url:                  requestConfig.Url,
+			RequestType:          requestConfig.RequestType,
+			ResponseTimeMs:       elapsed.Milliseconds(),
+			ExpectedResponseTime: requestConfig.ResponseTime,
+			Metrics:              metrics,
+		},
+		elapsed: elapsed,
+	}
+}
+
+// Converts a time to a NTP timestamp, seconds since 1900 with 32 bit fraction
+func toNtpTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+func fromNtpTime(timestamp uint64) time.Time {
+	seconds := int64(timestamp>>32) - ntpEpochOffset
+	nanoseconds := int64((timestamp & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(seconds, nanoseconds)
+}
+
+// Duration as milliseconds with fraction, offsets are often below a millisecond
+func fractionalMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// queries the time of a NTP server with SNTP (RFC 4330). The offset of the local clock and the
+// round trip delay are saved as metrics offsetMs and delayMs, the stratum of the server as stratum.
+func performNtpCheck(requestConfig RequestConfig) attemptResult {
+	metrics := make(map[string]float64)
+	target, _ := url.Parse(requestConfig.Url)
+	address := target.Host
+	if len(target.Port()) == 0 {
+		address = net.JoinHostPort(target.Hostname(), DefaultNtpPort)
+	}
+
+	start := time.Now()
+	conn, err := dialUdp(requestConfig, address)
+	if err != nil {
+		return udpTransportFailure(requestConfig, err, time.Since(start), metrics)
+	}
+	defer conn.Close()
+	if requestConfig._timeout > 0 {
+		conn.SetDeadline(start.Add(requestConfig._timeout))
+	}
+
+	// version 4, client mode
+	request := make([]byte, ntpPacketSize)
+	request[0] = 4<<3 | 3
+	transmit := time.Now()
+	binary.BigEndian.PutUint64(request[40:], toNtpTime(transmit))
+	if _, err := conn.Write(request); err != nil {
+		return udpTransportFailure(requestConfig, err, time.Since(start), metrics)
+	}
+
+	response := make([]byte, maxUdpMessage)
+	n, err := conn.Read(response)
+	received := time.Now()
+	elapsed := received.Sub(start)
+	if err != nil {
+		return udpTransportFailure(requestConfig, err, elapsed, metrics)
+	}
+
+	invalid := func(err error) attemptResult {
+		return udpFailure(requestConfig, err, database.ErrReadResponse, model.CategoryUnexpectedStatus, elapsed, false, metrics)
+	}
+	if n < ntpPacketSize {
+		return invalid(fmt.Errorf("Response of %d bytes is too short", n))
+	}
+	if response[0]&0x7 != ntpModeServer {
+		return invalid(fmt.Errorf("Response has mode %d instead of server", response[0]&0x7))
+	}
+	if binary.BigEndian.Uint64(response[24:]) != binary.BigEndian.Uint64(request[40:]) {
+		return invalid(errors.New("Response does not answer the request"))
+	}
+
+	stratum := int(response[1])
+	if stratum == 0 {
+		// kiss-o'-death, the reference id is the reason e.g. RATE or DENY
+		err := fmt.Errorf("Got kiss-o'-death %s", strings.TrimRight(string(response[12:16]), "\x00"))
+		return udpFailure(requestConfig, err, err, model.CategoryUnexpectedStatus, elapsed, true, metrics)
+	}
+	metrics["stratum"] = float64(stratum)
+
+	serverReceive := fromNtpTime(binary.BigEndian.Uint64(response[32:]))
+	serverTransmit := fromNtpTime(binary.BigEndian.Uint64(response[40:]))
+	offset := (serverReceive.Sub(transmit) + serverTransmit.Sub(received)) / 2
+	delay := received.Sub(transmit) - serverTransmit.Sub(serverReceive)
+	metrics["offsetMs"] = fractionalMs(offset)
+	metrics["delayMs"] = fractionalMs(delay)
+
+	if response[0]>>6 == ntpLeapAlarm {
+		err := errors.New("Server clock is not synchronized")
+		return udpFailure(requestConfig, err, database.ErrClockSkew, model.CategoryClockSkew, elapsed, false, metrics)
+	}
+	if requestConfig.Ntp != nil {
+		absOffset := offset
+		if absOffset < 0 {
+			absOffset = -absOffset
+		}
+		if requestConfig.Ntp._maxOffset > 0 && absOffset > requestConfig.Ntp._maxOffset {
+			err := fmt.Errorf("Clock offset %s exceeds maxOffset %s", offset, requestConfig.Ntp.MaxOffset)
+			return udpFailure(requestConfig, err, database.ErrClockSkew, model.CategoryClockSkew, elapsed, false, metrics)
+		}
+		if maxStratum := requestConfig.Ntp.MaxStratum; maxStratum != nil && stratum > *maxStratum {
+			err := fmt.Errorf("Stratum %d exceeds maxStratum %d", stratum, *maxStratum)
+			return udpFailure(requestConfig, err, database.ErrClockSkew, model.CategoryClockSkew, elapsed, false, metrics)
+		}
+	}
+
+	return attemptResult{
+		requestInfo: model.RequestInfo{
+			Id:                   requestConfig.Id,
+			Url:                  requestConfig.Url,
+			RequestType:          requestConfig.RequestType,
+			ResponseTimeMs:       elapsed.Milliseconds(),
+			ExpectedResponseTime: requestConfig.ResponseTime,
+			Metrics:              metrics,
+		},
+		elapsed: elapsed,
+	}
+}
@@ -0,0 +1,148 @@
+package requests
+
+import (
+	"encoding/binary"
+	"net"
+	"statusok/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// udp server answering each datagram with the result of respond, without answer if it returns nil
+func udpServer(t *testing.T, respond func(request []byte) []byte) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		buffer := make([]byte, maxUdpMessage)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			if response := respond(buffer[:n]); response != nil {
+				conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+// fake ntp server with a clock ahead by skew
+func fakeNtp(skew time.Duration, stratum byte, leap byte) func(request []byte) []byte {
+	return func(request []byte) []byte {
+		now := time.Now().Add(skew)
+		response := make([]byte, ntpPacketSize)
+		response[0] = leap<<6 | 4<<3 | ntpModeServer
+		response[1] = stratum
+		if stratum == 0 {
+			copy(response[12:], "RATE")
+		}
+		copy(response[24:32], request[40:48])
+		binary.BigEndian.PutUint64(response[32:], toNtpTime(now))
+		binary.BigEndian.PutUint64(response[40:], toNtpTime(now))
+		return response
+	}
+}
+
+func TestNtpTime(t *testing.T) {
+	now := time.Unix(1700000000, 123456789)
+	assert.Equal(t, uint64(1700000000+ntpEpochOffset), toNtpTime(now)>>32)
+	assert.InDelta(t, 0, fromNtpTime(toNtpTime(now)).Sub(now), float64(time.Microsecond))
+}
+
+func TestValidateUdp(t *testing.T) {
+	zero, four, sixteen := 0, 4, 16
+	for _, valid := range []RequestConfig{
+		{Type: TypeUdp, Url: "udp://dns:53", Udp: &UdpConfig{SendHex: "00010100"}, ResponseTime: 100},
+		{Type: TypeNtp, Url: "ntp://pool.ntp.org", Ntp: &NtpConfig{MaxOffset: "100ms", MaxStratum: &four}, ResponseTime: 100},
+	} {
+		assert.Nil(t, valid.Validate())
+	}
+
+	for _, invalid := range []RequestConfig{
+		{Type: TypeUdp, Url: "udp://dns", Udp: &UdpConfig{Send: "ping"}, ResponseTime: 100},
+		{Type: TypeUdp, Url: "udp://dns:53", ResponseTime: 100},
+		{Type: TypeUdp, Url: "udp://dns:53", Udp: &UdpConfig{Send: "ping", SendHex: "00"}, ResponseTime: 100},
+		{Type: TypeUdp, Url: "udp://dns:53", Udp: &UdpConfig{SendHex: "0g"}, ResponseTime: 100},
+		{Type: TypeUdp, Url: "udp://dns:53", Udp: &UdpConfig{Send: "ping", Expect: "("}, ResponseTime: 100},
+		{Type: TypeNtp, Url: "udp://pool.ntp.org:123", ResponseTime: 100},
+		{Type: TypeNtp, Url: "ntp://pool.ntp.org", Ntp: &NtpConfig{MaxOffset: "fast"}, ResponseTime: 100},
+		{Type: TypeNtp, Url: "ntp://pool.ntp.org", Ntp: &NtpConfig{MaxOffset: "-1s"}, ResponseTime: 100},
+		{Type: TypeNtp, Url: "ntp://pool.ntp.org", Ntp: &NtpConfig{MaxStratum: &zero}, ResponseTime: 100},
+		{Type: TypeNtp, Url: "ntp://pool.ntp.org", Ntp: &NtpConfig{MaxStratum: &sixteen}, ResponseTime: 100},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func TestUdpCheck(t *testing.T) {
+	address, closeServer := udpServer(t, func(request []byte) []byte {
+		if string(request) == "ping" {
+			return []byte("pong")
+		}
+		return nil
+	})
+	defer closeServer()
+
+	requestConfig := RequestConfig{Id: 1, Type: TypeUdp, Url: "udp://" + address, Udp: &UdpConfig{Send: "ping", Expect: "^pong$"}, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	assert.Equal(t, "UDP", requestConfig.RequestType)
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Contains(t, result.requestInfo.Metrics, "roundTripMs")
+
+	requestConfig.Udp.Expect = "^PONG"
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, `Response "pong" does not match "^PONG"`)
+	assert.Equal(t, model.CategoryAssertionFailed, result.errorInfo.Category)
+
+	requestConfig.Udp = &UdpConfig{SendHex: "00"}
+	requestConfig.Timeout = "100ms"
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.Equal(t, model.CategoryTimeout, result.errorInfo.Category)
+	assert.True(t, result.transient)
+}
+
+func TestNtpCheck(t *testing.T) {
+	address, closeServer := udpServer(t, fakeNtp(2*time.Second, 2, 0))
+	defer closeServer()
+
+	requestConfig := RequestConfig{Id: 1, Type: TypeNtp, Url: "ntp://" + address, ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.InDelta(t, 2000, result.requestInfo.Metrics["offsetMs"], 50)
+	assert.Equal(t, 2.0, result.requestInfo.Metrics["stratum"])
+	assert.Equal(t, 0, result.requestInfo.ResponseCode)
+	assert.Contains(t, result.requestInfo.Metrics, "delayMs")
+
+	requestConfig.Ntp = &NtpConfig{MaxOffset: "500ms"}
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.Contains(t, result.err.Error(), "exceeds maxOffset 500ms")
+	assert.Equal(t, model.CategoryClockSkew, result.errorInfo.Category)
+	assert.InDelta(t, 2000, result.errorInfo.Metrics["offsetMs"], 50)
+
+	maxStratum := 1
+	requestConfig.Ntp = &NtpConfig{MaxOffset: "5s", MaxStratum: &maxStratum}
+	assert.Nil(t, requestConfig.Validate())
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Stratum 2 exceeds maxStratum 1")
+	assert.Equal(t, model.CategoryClockSkew, result.errorInfo.Category)
+
+	unsynchronized, closeUnsynchronized := udpServer(t, fakeNtp(0, 2, ntpLeapAlarm))
+	defer closeUnsynchronized()
+	requestConfig.Url = "ntp://" + unsynchronized
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Server clock is not synchronized")
+
+	kissOfDeath, closeKissOfDeath := udpServer(t, fakeNtp(0, 0, 0))
+	defer closeKissOfDeath()
+	requestConfig.Url = "ntp://" + kissOfDeath
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, "Got kiss-o'-death RATE")
+	assert.Equal(t, model.CategoryUnexpectedStatus, result.errorInfo.Category)
+}
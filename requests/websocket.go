@@ -0,0 +1,369 @@
+package requests
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"statusok/database"
+	"statusok/model"
+	"strings"
+	"time"
+)
+
+const (
+	webSocketGuid       = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	maxWebSocketMessage = 1 << 20
+
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// WebSocketConfig is the exchange of a websocket check. Send is sent as text message after
+// the handshake, the first message received has to match the regular expression Expect.
+type WebSocketConfig struct {
+	Send         string   `json:"send"`
+	Expect       string   `json:"expect"`
+	Subprotocols []string `json:"subprotocols"`
+}
+
+// check the url of a websocket check, ws://host/path or wss://host/path
+func (requestConfig *RequestConfig) validateWebSocket() error {
+	target, err := url.Parse(requestConfig.Url)
+	if err != nil || len(target.Host) == 0 {
+		return errors.New("Invalid Url")
+	}
+	if target.Scheme != "ws" && target.Scheme != "wss" {
+		return fmt.Errorf("Invalid Url %s, use ws:// or wss://", requestConfig.Url)
+	}
+	if len(requestConfig.Proxy) != 0 {
+		return errors.New("Proxy cannot be given for a check of type websocket")
+	}
+	if requestConfig.Auth != nil && requestConfig.Auth.Type == AuthDigest {
+		return errors.New("Digest auth cannot be used for a check of type websocket")
+	}
+	if requestConfig.WebSocket != nil && len(requestConfig.WebSocket.Expect) != 0 {
+		if _, err := regexp.Compile(requestConfig.WebSocket.Expect); err != nil {
+			return fmt.Errorf("Expect has an invalid regular expression: %s", err)
+		}
+	}
+	if len(requestConfig.RequestType) == 0 {
+		requestConfig.RequestType = strings.ToUpper(TypeWebSocket)
+	}
+	return nil
+}
+
+// Connects to the host of the url, with TLS for wss://
+func dialWebSocket(requestConfig RequestConfig, target *url.URL) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: requestConfig._timeout}
+	if len(requestConfig.SourceAddress) != 0 {
+		ip := net.ParseIP(requestConfig.SourceAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid sourceAddress %s", requestConfig.SourceAddress)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	address := target.Host
+	if len(target.Port()) == 0 {
+		if target.Scheme == "wss" {
+			address = net.JoinHostPort(target.Hostname(), "443")
+		} else {
+			address = net.JoinHostPort(target.Hostname(), "80")
+		}
+	}
+
+	if target.Scheme != "wss" {
+		return dialer.Dial("tcp", address)
+	}
+
+	tlsConfig := &tls.Config{}
+	if requestConfig.TLS != nil {
+		var err error
+		if tlsConfig, err = requestConfig.TLS.TLSConfig(); err != nil {
+			return nil, err
+		}
+	}
+	if len(tlsConfig.ServerName) == 0 {
+		tlsConfig.ServerName = target.Hostname()
+	}
+	return tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+}
+
+// Writes a single masked frame as required for clients
+func writeWebSocketFrame(w io.Writer, opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(payload)))
+	}
+
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return err
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// Reads a single frame
+func readWebSocketFrame(r io.Reader) (fin bool, opcode byte, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err = io.ReadFull(r, extended); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err = io.ReadFull(r, extended); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if length > maxWebSocketMessage {
+		err = fmt.Errorf("Frame of %d bytes is too large", length)
+		return
+	}
+
+	mask := make([]byte, 4)
+	if masked {
+		if _, err = io.ReadFull(r, mask); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// Reads the first text or binary message, answering pings in between
+func readWebSocketMessage(conn io.ReadWriter, r io.Reader) ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := readWebSocketFrame(r)
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := writeWebSocketFrame(conn, opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code := 0
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			return nil, fmt.Errorf("Connection closed by server with code %d", code)
+		case opText, opBinary, opContinuation:
+			message = append(message, payload...)
+			if len(message) > maxWebSocketMessage {
+				return nil, errors.New("Message is too large")
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, fmt.Errorf("Unknown opcode %d", opcode)
+		}
+	}
+}
+
+// performs the websocket handshake, sends the message and waits for the first message if given.
+// Handshake and round trip times are saved as metrics handshakeMs and roundTripMs.
+func performWebSocketCheck(requestConfig RequestConfig) attemptResult {
+	metrics := make(map[string]float64)
+	failure := func(err error, reason error, category string, status int, elapsed time.Duration, transient bool) attemptResult {
+		return attemptResult{
+			errorInfo: &model.ErrorInfo{
+				Id:             requestConfig.Id,
+				Url:            requestConfig.Url,
+				RequestType:    requestConfig.RequestType,
+				ResponseCode:   status,
+				ResponseTimeMs: elapsed.Milliseconds(),
+				Reason:         reason,
+				Category:       category,
+				OtherInfo:      err.Error(),
+				Metrics:        metrics,
+			},
+			err:       err,
+			transient: transient,
+			elapsed:   elapsed,
+		}
+	}
+	transportFailure := func(err error, elapsed time.Duration) attemptResult {
+		category := classifyError(err)
+		reason := database.ErrDoRequest
+		if category == model.CategoryTimeout {
+			reason = database.ErrTimeout
+		}
+		return failure(err, reason, category, 0, elapsed, true)
+	}
+
+	target, err := url.Parse(requestConfig.Url)
+	if err != nil {
+		return failure(err, database.ErrCreateRequest, model.CategoryInvalidRequest, 0, 0, false)
+	}
+	exchange := WebSocketConfig{}
+	if requestConfig.WebSocket != nil {
+		exchange = *requestConfig.WebSocket
+	}
+
+	// the handshake is a http request to the http(s) url
+	httpUrl := *target
+	httpUrl.Scheme = strings.Replace(target.Scheme, "ws", "http", 1)
+	request, err := http.NewRequest(http.MethodGet, httpUrl.String(), nil)
+	if err != nil {
+		return failure(err, database.ErrCreateRequest, model.CategoryInvalidRequest, 0, 0, false)
+	}
+	keyBytes := make([]byte, 16)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return failure(err, database.ErrCreateRequest, model.CategoryInvalidRequest, 0, 0, false)
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	if len(exchange.Subprotocols) != 0 {
+		request.Header.Set("Sec-WebSocket-Protocol", strings.Join(exchange.Subprotocols, ", "))
+	}
+	request.Header.Set(UserAgent, DefaultUserAgent)
+	AddHeaders(request, requestConfig.Headers)
+
+	if requestConfig.Auth != nil {
+		client, err := newHttpClient(requestConfig)
+		if err == nil {
+			err = requestConfig.Auth.apply(request, client)
+		}
+		if err != nil {
+			return failure(err, database.ErrTokenFetch, model.CategoryTokenFetch, 0, 0, true)
+		}
+	}
+
+	start := time.Now()
+	conn, err := dialWebSocket(requestConfig, target)
+	if err != nil {
+		return transportFailure(err, time.Since(start))
+	}
+	defer conn.Close()
+	if requestConfig._timeout > 0 {
+		conn.SetDeadline(start.Add(requestConfig._timeout))
+	}
+
+	if err := request.Write(conn); err != nil {
+		return transportFailure(err, time.Since(start))
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	handshake := time.Since(start)
+	metrics["handshakeMs"] = float64(handshake.Milliseconds())
+	if err != nil {
+		return transportFailure(err, handshake)
+	}
+
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		err := fmt.Errorf("Got Response code %d. Expected Response Code 101 ", response.StatusCode)
+		result := failure(err, err, model.CategoryUnexpectedStatus, response.StatusCode, handshake, response.StatusCode >= http.StatusInternalServerError)
+		result.errorInfo.Headers = getResponseHeaders(response)
+		result.errorInfo.ResponseBody = convertResponseToString(response)
+		return result
+	}
+	accept := sha1.Sum([]byte(key + webSocketGuid))
+	if response.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(accept[:]) {
+		err := errors.New("Sec-WebSocket-Accept of the handshake is invalid")
+		return failure(err, err, model.CategoryAssertionFailed, response.StatusCode, handshake, false)
+	}
+
+	elapsed := handshake
+	if len(exchange.Send) != 0 || len(exchange.Expect) != 0 {
+		sent := time.Now()
+		if len(exchange.Send) != 0 {
+			if err := writeWebSocketFrame(conn, opText, []byte(exchange.Send)); err != nil {
+				return transportFailure(err, time.Since(start))
+			}
+		}
+		message, err := readWebSocketMessage(conn, reader)
+		roundTrip := time.Since(sent)
+		metrics["roundTripMs"] = float64(roundTrip.Milliseconds())
+		elapsed = time.Since(start)
+		if err != nil {
+			return transportFailure(err, elapsed)
+		}
+
+		if len(exchange.Expect) != 0 {
+			re, err := regexp.Compile(exchange.Expect)
+			if err != nil {
+				return failure(err, database.ErrCreateRequest, model.CategoryInvalidRequest, response.StatusCode, elapsed, false)
+			}
+			if !re.Match(message) {
+				err := fmt.Errorf("Message %q does not match %q", message, exchange.Expect)
+				result := failure(err, err, model.CategoryAssertionFailed, response.StatusCode, elapsed, false)
+				result.errorInfo.ResponseBody = string(message)
+				return result
+			}
+		}
+	}
+
+	// close the connection properly, errors do not matter anymore
+	writeWebSocketFrame(conn, opClose, []byte{0x03, 0xe8})
+
+	return attemptResult{
+		requestInfo: model.RequestInfo{
+			Id:                   requestConfig.Id,
+			Url:                  requestConfig.Url,
+			RequestType:          requestConfig.RequestType,
+			ResponseCode:         response.StatusCode,
+			ResponseTimeMs:       elapsed.Milliseconds(),
+			ExpectedResponseTime: requestConfig.ResponseTime,
+			Metrics:              metrics,
+		},
+		elapsed: elapsed,
+		headers: getResponseHeaders(response),
+	}
+}
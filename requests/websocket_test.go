@@ -0,0 +1,119 @@
+package requests
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"statusok/model"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// websocket server answering every message with "echo: <message>" after a ping
+func webSocketEchoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("X-Token") != "abc" {
+			w.WriteHeader(http.StatusUpgradeRequired)
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		accept := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + webSocketGuid))
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n")
+		buf.Flush()
+
+		for {
+			_, opcode, payload, err := readWebSocketFrame(buf)
+			if err != nil || opcode == opClose {
+				return
+			}
+			if opcode == opText {
+				// unmasked frames from the server
+				conn.Write([]byte{0x80 | opPing, 0})
+				reply := append([]byte("echo: "), payload...)
+				conn.Write(append([]byte{0x80 | opText, byte(len(reply))}, reply...))
+			}
+		}
+	}))
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	var buf bytes.Buffer
+	message := []byte(strings.Repeat("x", 300))
+	assert.Nil(t, writeWebSocketFrame(&buf, opText, message))
+
+	fin, opcode, payload, err := readWebSocketFrame(&buf)
+	assert.Nil(t, err)
+	assert.True(t, fin)
+	assert.Equal(t, byte(opText), opcode)
+	assert.Equal(t, message, payload)
+}
+
+func TestValidateWebSocket(t *testing.T) {
+	requestConfig := RequestConfig{Type: TypeWebSocket, Url: "wss://realtime.test/socket", ResponseTime: 100}
+	assert.Nil(t, requestConfig.Validate())
+	assert.Equal(t, "WEBSOCKET", requestConfig.RequestType)
+
+	for _, invalid := range []RequestConfig{
+		{Type: TypeWebSocket, Url: "http://realtime.test/socket", ResponseTime: 100},
+		{Type: TypeWebSocket, Url: "ws://realtime.test/socket", ResponseTime: 100, WebSocket: &WebSocketConfig{Expect: "("}},
+		{Type: TypeWebSocket, Url: "ws://realtime.test/socket", ResponseTime: 100, Auth: &AuthConfig{Type: AuthDigest, Username: "monitor"}},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func TestWebSocketCheck(t *testing.T) {
+	server := webSocketEchoServer(t)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/socket"
+	headers := map[string]string{"X-Token": "abc"}
+
+	requestConfig := RequestConfig{Id: 1, Type: TypeWebSocket, Url: url, ResponseTime: 100, Headers: headers,
+		WebSocket: &WebSocketConfig{Send: "ping", Expect: "^echo: ping$"}}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.Equal(t, http.StatusSwitchingProtocols, result.requestInfo.ResponseCode)
+	assert.Contains(t, result.requestInfo.Metrics, "handshakeMs")
+	assert.Contains(t, result.requestInfo.Metrics, "roundTripMs")
+
+	// only the handshake
+	requestConfig.WebSocket = nil
+	result = performAttempt(requestConfig)
+	assert.Nil(t, result.err)
+	assert.NotContains(t, result.requestInfo.Metrics, "roundTripMs")
+
+	requestConfig.WebSocket = &WebSocketConfig{Send: "ping", Expect: "^pong"}
+	result = performAttempt(requestConfig)
+	assert.EqualError(t, result.err, `Message "echo: ping" does not match "^pong"`)
+	assert.Equal(t, model.CategoryAssertionFailed, result.errorInfo.Category)
+
+	// the upgrade is refused
+	requestConfig.Headers = nil
+	result = performAttempt(requestConfig)
+	assert.Equal(t, http.StatusUpgradeRequired, result.errorInfo.ResponseCode)
+	assert.Equal(t, model.CategoryUnexpectedStatus, result.errorInfo.Category)
+}
+
+func TestWebSocketTimeout(t *testing.T) {
+	server := webSocketEchoServer(t)
+	defer server.Close()
+
+	// the server never sends a message on its own
+	requestConfig := RequestConfig{Id: 1, Type: TypeWebSocket, Url: "ws" + strings.TrimPrefix(server.URL, "http"), ResponseTime: 100,
+		Headers: map[string]string{"X-Token": "abc"}, Timeout: "200ms", WebSocket: &WebSocketConfig{Expect: "hello"}}
+	assert.Nil(t, requestConfig.Validate())
+	result := performAttempt(requestConfig)
+	assert.Equal(t, model.CategoryTimeout, result.errorInfo.Category)
+	assert.True(t, result.transient)
+}
@@ -0,0 +1,81 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var rruleWeekdays = map[string]string{"SU": "0", "MO": "1", "TU": "2", "WE": "3", "TH": "4", "FR": "5", "SA": "6"}
+
+// Parse a recurrence rule in RFC 5545 RRULE format like "FREQ=WEEKLY;BYDAY=TU;BYHOUR=22;BYMINUTE=0".
+// Supported are FREQ, INTERVAL=1, BYMINUTE, BYHOUR, BYDAY, BYMONTHDAY and BYMONTH.
+// Values not given by the rule default to the start of the period, as there is no DTSTART.
+func ParseRRule(rule string, location *time.Location) (*Schedule, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if len(rule) == 0 {
+		return nil, ErrEmptyExpression
+	}
+
+	parts := make(map[string]string)
+	for _, part := range strings.Split(rule, ";") {
+		keyValue := strings.SplitN(part, "=", 2)
+		if len(keyValue) != 2 {
+			return nil, fmt.Errorf("Invalid RRULE \"%s\": \"%s\" is not a key value pair", rule, part)
+		}
+		parts[strings.ToUpper(keyValue[0])] = strings.ToUpper(keyValue[1])
+	}
+
+	minute, hour, day, month, weekday := "0", "0", "*", "*", "*"
+
+	switch parts["FREQ"] {
+	case "MINUTELY":
+		minute, hour = "*", "*"
+	case "HOURLY":
+		hour = "*"
+	case "DAILY":
+	case "WEEKLY":
+		weekday = "1"
+	case "MONTHLY":
+		day = "1"
+	case "YEARLY":
+		day, month = "1", "1"
+	default:
+		return nil, fmt.Errorf("Invalid RRULE \"%s\": unsupported FREQ \"%s\"", rule, parts["FREQ"])
+	}
+	delete(parts, "FREQ")
+
+	for key, value := range parts {
+		switch key {
+		case "INTERVAL":
+			if value != "1" {
+				return nil, fmt.Errorf("Invalid RRULE \"%s\": only INTERVAL=1 is supported", rule)
+			}
+		case "BYMINUTE":
+			minute = value
+		case "BYHOUR":
+			hour = value
+		case "BYMONTHDAY":
+			day = value
+		case "BYMONTH":
+			month = value
+		case "BYDAY":
+			days := strings.Split(value, ",")
+			for i, d := range days {
+				number, ok := rruleWeekdays[d]
+				if !ok {
+					return nil, fmt.Errorf("Invalid RRULE \"%s\": unsupported BYDAY value \"%s\"", rule, d)
+				}
+				days[i] = number
+			}
+			weekday = strings.Join(days, ",")
+			if day == "1" && parts["BYMONTHDAY"] == "" {
+				day = "*"
+			}
+		default:
+			return nil, fmt.Errorf("Invalid RRULE \"%s\": unsupported part \"%s\"", rule, key)
+		}
+	}
+
+	return Parse(strings.Join([]string{minute, hour, day, month, weekday}, " "), location)
+}
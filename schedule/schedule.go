@@ -0,0 +1,217 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression with minute resolution.
+// Times are matched in the location of the schedule.
+type Schedule struct {
+	minutes  uint64 // bit set of minutes 0-59
+	hours    uint64 // bit set of hours 0-23
+	days     uint64 // bit set of days of month 1-31
+	months   uint64 // bit set of months 1-12
+	weekdays uint64 // bit set of weekdays 0-6, sunday is 0
+	// cron matches either day of month or weekday when both are restricted
+	anyDay     bool
+	anyWeekday bool
+	location   *time.Location
+}
+
+type field struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField  = field{0, 59, nil}
+	hourField    = field{0, 23, nil}
+	dayField     = field{1, 31, nil}
+	monthField   = field{1, 12, map[string]int{"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6, "JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12}}
+	weekdayField = field{0, 7, map[string]int{"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6}}
+
+	descriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+
+	ErrEmptyExpression = errors.New("Schedule expression cannot be empty")
+)
+
+// Parse a cron expression with the fields minute, hour, day of month, month and day of week.
+// Lists, ranges, steps, names (MON, JAN) and descriptors like @daily are supported.
+func Parse(expression string, location *time.Location) (*Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	if len(expression) == 0 {
+		return nil, ErrEmptyExpression
+	}
+	if location == nil {
+		location = time.Local
+	}
+
+	if spec, ok := descriptors[strings.ToLower(expression)]; ok {
+		expression = spec
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Invalid cron expression \"%s\": expected 5 fields, got %d", expression, len(fields))
+	}
+
+	schedule := &Schedule{location: location}
+	var err error
+
+	if schedule.minutes, err = minuteField.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("Invalid minute in cron expression \"%s\": %s", expression, err)
+	}
+	if schedule.hours, err = hourField.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("Invalid hour in cron expression \"%s\": %s", expression, err)
+	}
+	if schedule.days, err = dayField.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("Invalid day of month in cron expression \"%s\": %s", expression, err)
+	}
+	if schedule.months, err = monthField.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("Invalid month in cron expression \"%s\": %s", expression, err)
+	}
+	if schedule.weekdays, err = weekdayField.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("Invalid day of week in cron expression \"%s\": %s", expression, err)
+	}
+	// 7 is an alias for sunday
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+
+	schedule.anyDay = fields[2] == "*" || fields[2] == "?"
+	schedule.anyWeekday = fields[4] == "*" || fields[4] == "?"
+
+	return schedule, nil
+}
+
+// Parse a list of comma separated values, ranges and steps to a bit set
+func (f field) parse(expression string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(expression, ",") {
+		step := 1
+		hasStep := false
+		if i := strings.Index(part, "/"); i >= 0 {
+			hasStep = true
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step \"%s\"", part[i+1:])
+			}
+			part = part[:i]
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range \"%s\"", part)
+			}
+		default:
+			var err error
+			if start, err = f.value(part); err != nil {
+				return 0, err
+			}
+			// a single value with a step means every step starting at value
+			if hasStep {
+				end = f.max
+			} else {
+				end = start
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value \"%s\"", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Location the schedule is evaluated in
+func (schedule *Schedule) Location() *time.Location {
+	return schedule.location
+}
+
+// Tells whether the minute of t matches the schedule
+func (schedule *Schedule) Matches(t time.Time) bool {
+	t = t.In(schedule.location)
+
+	return schedule.minutes&(1<<uint(t.Minute())) != 0 &&
+		schedule.hours&(1<<uint(t.Hour())) != 0 &&
+		schedule.months&(1<<uint(t.Month())) != 0 &&
+		schedule.matchesDay(t)
+}
+
+func (schedule *Schedule) matchesDay(t time.Time) bool {
+	day := schedule.days&(1<<uint(t.Day())) != 0
+	weekday := schedule.weekdays&(1<<uint(t.Weekday())) != 0
+
+	if schedule.anyDay || schedule.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// Returns the first time after t matching the schedule.
+// Returns the zero time if there is none within the next five years.
+func (schedule *Schedule) Next(t time.Time) time.Time {
+	t = t.In(schedule.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if schedule.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, schedule.location)
+			continue
+		}
+		if !schedule.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, schedule.location)
+			continue
+		}
+		if schedule.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, schedule.location)
+			continue
+		}
+		if schedule.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,75 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInvalid(t *testing.T) {
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "* * * FOO *"} {
+		_, err := Parse(expression, time.UTC)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestNext(t *testing.T) {
+	start := time.Date(2021, time.September, 6, 10, 30, 20, 0, time.UTC) // monday
+
+	tests := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"* * * * *", time.Date(2021, time.September, 6, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, time.September, 6, 10, 45, 0, 0, time.UTC)},
+		{"0 22 * * TUE", time.Date(2021, time.September, 7, 22, 0, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2021, time.September, 6, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2021, time.September, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 JAN *", time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2021, time.September, 10, 0, 0, 0, 0, time.UTC)},
+		{"30 10 * * 7", time.Date(2021, time.September, 12, 10, 30, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		schedule, err := Parse(test.expression, time.UTC)
+		assert.Nil(t, err, test.expression)
+		assert.Equal(t, test.expected, schedule.Next(start), test.expression)
+		assert.True(t, schedule.Matches(test.expected), test.expression)
+	}
+}
+
+func TestNextInLocation(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	schedule, err := Parse("0 22 * * *", location)
+	assert.Nil(t, err)
+
+	start := time.Date(2021, time.September, 6, 12, 0, 0, 0, time.UTC)
+	assert.True(t, schedule.Next(start).Equal(time.Date(2021, time.September, 6, 20, 0, 0, 0, time.UTC)))
+}
+
+func TestParseRRule(t *testing.T) {
+	start := time.Date(2021, time.September, 6, 10, 30, 0, 0, time.UTC) // monday
+
+	tests := []struct {
+		rule     string
+		expected time.Time
+	}{
+		{"FREQ=WEEKLY;BYDAY=TU;BYHOUR=22;BYMINUTE=0", time.Date(2021, time.September, 7, 22, 0, 0, 0, time.UTC)},
+		{"RRULE:FREQ=DAILY;BYHOUR=3", time.Date(2021, time.September, 7, 3, 0, 0, 0, time.UTC)},
+		{"FREQ=HOURLY;BYMINUTE=45", time.Date(2021, time.September, 6, 10, 45, 0, 0, time.UTC)},
+		{"FREQ=MONTHLY;BYMONTHDAY=15;BYHOUR=1", time.Date(2021, time.September, 15, 1, 0, 0, 0, time.UTC)},
+		{"FREQ=MONTHLY;BYDAY=SA", time.Date(2021, time.September, 11, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		schedule, err := ParseRRule(test.rule, time.UTC)
+		assert.Nil(t, err, test.rule)
+		assert.Equal(t, test.expected, schedule.Next(start), test.rule)
+	}
+
+	for _, rule := range []string{"", "FREQ=SECONDLY", "FREQ=DAILY;INTERVAL=2", "FREQ=WEEKLY;BYDAY=XX", "FREQ=DAILY;COUNT=3"} {
+		_, err := ParseRRule(rule, time.UTC)
+		assert.Error(t, err, rule)
+	}
+}
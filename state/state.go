@@ -0,0 +1,103 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Sections of the state file
+const (
+	SectionContent = "content"
+	SectionAudit   = "audit"
+)
+
+var (
+	mutex    sync.Mutex
+	fileName string                     // file the state is saved to, empty to keep it in memory
+	sections map[string]json.RawMessage // state of each package by section
+)
+
+// Loads the state file, which keeps baselines across restarts. Each package saves its state in
+// a section of the file. A missing file is created on the first save.
+func Load(file string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	fileName = file
+	sections = make(map[string]json.RawMessage)
+	if len(file) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Reading state file failed: %s", err)
+	}
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return fmt.Errorf("State file %s is invalid: %s", file, err)
+	}
+	return nil
+}
+
+// Decodes the section into value, which is left unchanged if the state file has no such section
+func Get(section string, value interface{}) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	data, found := sections[section]
+	if !found {
+		return nil
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("Section %s of state file %s is invalid: %s", section, fileName, err)
+	}
+	return nil
+}
+
+// Saves value as the section, replacing the state file only once it is written completely
+func Save(section string, value interface{}) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(fileName) == 0 {
+		return nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	sections[section] = encoded
+	data, err := json.MarshalIndent(sections, "", "\t")
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), fileName)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// Name of the state file, empty if the state is kept in memory only
+func FileName() string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return fileName
+}
@@ -0,0 +1,48 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer Load("")
+	file := filepath.Join(dir, "state.json")
+
+	// a missing file is created on the first save
+	assert.Nil(t, Load(file))
+	assert.Nil(t, Save(SectionContent, map[string]string{"homepage": "abc"}))
+	assert.Nil(t, Save(SectionAudit, map[string][]string{"GET https://mywebsite.com": {"hsts"}}))
+
+	// the sections are kept across restarts
+	assert.Nil(t, Load(file))
+	var content map[string]string
+	assert.Nil(t, Get(SectionContent, &content))
+	assert.Equal(t, map[string]string{"homepage": "abc"}, content)
+	var audit map[string][]string
+	assert.Nil(t, Get(SectionAudit, &audit))
+	assert.Equal(t, []string{"hsts"}, audit["GET https://mywebsite.com"])
+
+	var missing map[string]string
+	assert.Nil(t, Get("other", &missing))
+	assert.Nil(t, missing)
+	assert.Error(t, Get(SectionContent, &audit))
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1)
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte("{"), 0600))
+	assert.Error(t, Load(file))
+
+	// without a file the state is kept in memory only
+	assert.Nil(t, Load(""))
+	assert.Nil(t, Save(SectionContent, content))
+	assert.Equal(t, "", FileName())
+}
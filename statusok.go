@@ -9,8 +9,11 @@ import (
 	"os"
 	"statusok/database"
 	"statusok/logger"
+	"statusok/maintenance"
 	"statusok/notify"
 	"statusok/requests"
+	"statusok/state"
+	"statusok/tlsutil"
 	"time"
 
 	"github.com/urfave/cli"
@@ -21,12 +24,18 @@ const (
 )
 
 type configuration struct {
-	NotifyWhen    NotifyWhen               `json:"notifyWhen"`
-	Requests      []requests.RequestConfig `json:"requests"`
-	Notifications notify.NotificationTypes `json:"notifications"`
-	Database      database.DatabaseTypes   `json:"database"`
-	Concurrency   int                      `json:"concurrency"`
-	Port          int                      `json:"port"`
+	NotifyWhen       NotifyWhen               `json:"notifyWhen"`
+	Requests         []requests.RequestConfig `json:"requests"`
+	Notifications    notify.NotificationTypes `json:"notifications"`
+	Database         database.DatabaseTypes   `json:"database"`
+	Concurrency      int                      `json:"concurrency"`
+	Port             int                      `json:"port"`
+	SpreadChecks     string                   `json:"spreadChecks"`
+	FlapDetection    *database.FlapDetection  `json:"flapDetection"`
+	Maintenance      []maintenance.Window     `json:"maintenanceWindows"`
+	MaintenanceToken string                   `json:"maintenanceToken"`
+	TLS              *tlsutil.Config          `json:"tls"`
+	StateFile        string                   `json:"stateFile"`
 }
 
 type NotifyWhen struct {
@@ -103,14 +112,26 @@ func readConfig(configFilename string) (configuration, error) {
 func startMonitoring(config configuration, logFileName string) {
 	var err error
 
-	// setup different notification clients
+	// setup different notification clients, they use the default tls settings like the requests
+	config.Notifications.SetTLSDefaults(config.TLS)
 	notify.AddNew(config.Notifications)
 	// Send test notifications to all the notification clients
 	notify.SendTestNotification()
 
+	// Requests use the default tls settings for the ones they do not give
+	for i := range config.Requests {
+		config.Requests[i].TLS = config.Requests[i].TLS.WithDefaults(config.TLS)
+	}
+
 	// Create unique ids for each request date given in config file
 	reqs, ids := validateAndCreateIdsForRequests(config.Requests)
 
+	err = requests.ValidateSpread(config.SpreadChecks)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(3)
+	}
+
 	// Set up and initialize databases
 
 	err = database.ParseDBConfig(config.Database)
@@ -120,14 +141,56 @@ func startMonitoring(config configuration, logFileName string) {
 	}
 	database.Initialize(ids, config.NotifyWhen.MinResponseCount, config.NotifyWhen.ErrorCount)
 
+	if config.FlapDetection != nil {
+		database.EnableFlapDetection(*config.FlapDetection)
+	}
+
+	err = maintenance.Initialize(config.Maintenance)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(3)
+	}
+
+	for _, requestConfig := range reqs {
+		if requestConfig.Anomaly != nil {
+			database.EnableAnomalyDetection(requestConfig.Id, *requestConfig.Anomaly)
+		}
+		database.SetDependencies(requestConfig.Id, requests.GetDependencyIds(reqs, requestConfig))
+		maintenance.RegisterCheck(maintenance.Check{
+			Id:    requestConfig.Id,
+			Name:  requestConfig.Name,
+			Group: requestConfig.Group,
+			Url:   requestConfig.Url,
+		})
+	}
+
+	// Content and audit baselines are kept across restarts in the state file
+	if len(config.StateFile) != 0 {
+		err = state.Load(config.StateFile)
+		if err == nil {
+			err = requests.LoadContentBaselines()
+		}
+		if err == nil {
+			err = database.LoadAuditFindings()
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(3)
+		}
+	}
+
 	// Initialize and start monitoring all the apis
 	requests.RequestsInit(reqs, config.Concurrency)
-	requests.StartMonitoring()
+	requests.StartMonitoring(config.SpreadChecks)
 
 	logger.EnableLogging(logFileName)
 
 	// Just to check StatusOk is running or not
 	http.HandleFunc("/", statusHandler)
+	// Create ad-hoc maintenance windows, only with a token as the api can silence every notification
+	if len(config.MaintenanceToken) != 0 {
+		http.HandleFunc("/maintenance", maintenance.HttpHandler(config.MaintenanceToken))
+	}
 
 	usedPort := config.Port
 	if usedPort == 0 {
@@ -141,10 +204,13 @@ func startMonitoring(config configuration, logFileName string) {
 	}
 }
 
-// Currently just tells status ok is running
-// Planning to display useful information in future
+// Tells status ok is running and the current status of each request
 func statusHandler(w http.ResponseWriter, r *http.Request) {
-	io.WriteString(w, "StatusOk is running.\n Maybe display other useful information in further releases...?")
+	io.WriteString(w, "StatusOk is running.\n")
+
+	for _, requestConfig := range requests.RequestsList {
+		fmt.Fprintf(w, "%s %s: %s\n", requestConfig.RequestType, requestConfig.Url, database.GetCheckStatus(requestConfig.Id))
+	}
 }
 
 // Tells whether a file exits or not
@@ -194,5 +260,10 @@ func validateAndCreateIdsForRequests(reqs []requests.RequestConfig) ([]requests.
 		newreqs = append(newreqs, requestConfig)
 	}
 
+	if err := requests.ValidateDependencies(newreqs); err != nil {
+		fmt.Printf("Invalid dependsOn in config file: %s\n", err.Error())
+		os.Exit(3)
+	}
+
 	return newreqs, ids
 }
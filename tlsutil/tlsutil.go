@@ -0,0 +1,144 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// TLS versions accepted as minVersion
+var Versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Config are the TLS settings of a check or a notification client: a client
+// certificate for mutual TLS, a CA bundle to verify the server, the server name to verify,
+// the minimum TLS version and whether verifying the server is skipped.
+type Config struct {
+	CertFile           string `json:"certFile"`
+	KeyFile            string `json:"keyFile"`
+	CaFile             string `json:"caFile"`
+	ServerName         string `json:"serverName"`
+	MinVersion         string `json:"minVersion"`
+	InsecureSkipVerify *bool  `json:"insecureSkipVerify"`
+
+	_tlsConfig *tls.Config     `json:"-"`
+	_transport *http.Transport `json:"-"`
+}
+
+// Returns the settings with the ones not given taken from defaults.
+// Returns nil if neither is given.
+func (config *Config) WithDefaults(defaults *Config) *Config {
+	if defaults == nil {
+		return config
+	}
+	if config == nil {
+		merged := *defaults
+		return &merged
+	}
+
+	merged := *config
+	if len(merged.CertFile) == 0 && len(merged.KeyFile) == 0 {
+		merged.CertFile = defaults.CertFile
+		merged.KeyFile = defaults.KeyFile
+	}
+	if len(merged.CaFile) == 0 {
+		merged.CaFile = defaults.CaFile
+	}
+	if len(merged.ServerName) == 0 {
+		merged.ServerName = defaults.ServerName
+	}
+	if len(merged.MinVersion) == 0 {
+		merged.MinVersion = defaults.MinVersion
+	}
+	// an explicit false of the request verifies the server even if the default skips it
+	if merged.InsecureSkipVerify == nil {
+		merged.InsecureSkipVerify = defaults.InsecureSkipVerify
+	}
+	merged._tlsConfig = nil
+	merged._transport = nil
+	return &merged
+}
+
+// check whether the settings are valid and load the certificates
+func (config *Config) Validate() error {
+	tlsConfig := &tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify != nil && *config.InsecureSkipVerify,
+	}
+
+	if len(config.CertFile) != 0 || len(config.KeyFile) != 0 {
+		if len(config.CertFile) == 0 || len(config.KeyFile) == 0 {
+			return errors.New("Both certFile and keyFile have to be given for a client certificate")
+		}
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return fmt.Errorf("Client certificate cannot be loaded: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if len(config.CaFile) != 0 {
+		data, err := ioutil.ReadFile(config.CaFile)
+		if err != nil {
+			return fmt.Errorf("CaFile cannot be read: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("CaFile %s contains no PEM certificates", config.CaFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(config.MinVersion) != 0 {
+		version, ok := Versions[config.MinVersion]
+		if !ok {
+			return fmt.Errorf("Invalid minVersion %s, use 1.0, 1.1, 1.2 or 1.3", config.MinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	config._tlsConfig = tlsConfig
+	config._transport = http.DefaultTransport.(*http.Transport).Clone()
+	config._transport.TLSClientConfig = tlsConfig.Clone()
+	return nil
+}
+
+// Returns the crypto/tls configuration, loading the certificates if not validated yet
+func (config *Config) TLSConfig() (*tls.Config, error) {
+	if config._tlsConfig == nil {
+		if err := config.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return config._tlsConfig.Clone(), nil
+}
+
+// Returns a transport like http.DefaultTransport using the settings.
+// The transport created by Validate is shared so connections are reused.
+func (config *Config) Transport() (*http.Transport, error) {
+	if config._transport == nil {
+		if err := config.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return config._transport, nil
+}
+
+// Returns a http client using the settings, the default client if config is nil
+func (config *Config) Client() (*http.Client, error) {
+	if config == nil {
+		return &http.Client{}, nil
+	}
+	transport, err := config.Transport()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport}, nil
+}
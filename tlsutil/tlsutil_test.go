@@ -0,0 +1,50 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDefaults(t *testing.T) {
+	var none *Config
+	assert.Nil(t, none.WithDefaults(nil))
+
+	defaults := &Config{CaFile: "/etc/ca.pem", MinVersion: "1.2", CertFile: "/etc/client.pem", KeyFile: "/etc/client.key"}
+	assert.Equal(t, defaults, none.WithDefaults(defaults))
+
+	own := &Config{CertFile: "/etc/other.pem", KeyFile: "/etc/other.key", ServerName: "internal"}
+	merged := own.WithDefaults(defaults)
+	assert.Equal(t, &Config{CertFile: "/etc/other.pem", KeyFile: "/etc/other.key", CaFile: "/etc/ca.pem", ServerName: "internal", MinVersion: "1.2"}, merged)
+	assert.Equal(t, "", own.CaFile)
+
+	// a request can verify the server although the default skips it
+	skip, verify := true, false
+	defaults.InsecureSkipVerify = &skip
+	assert.True(t, *none.WithDefaults(defaults).InsecureSkipVerify)
+	assert.True(t, *own.WithDefaults(defaults).InsecureSkipVerify)
+	assert.False(t, *(&Config{InsecureSkipVerify: &verify}).WithDefaults(defaults).InsecureSkipVerify)
+}
+
+func TestValidate(t *testing.T) {
+	skip := true
+	config := &Config{MinVersion: "1.3", ServerName: "internal", InsecureSkipVerify: &skip}
+	assert.Nil(t, config.Validate())
+
+	tlsConfig, err := config.TLSConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+	assert.Equal(t, "internal", tlsConfig.ServerName)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+
+	invalid := []*Config{
+		{MinVersion: "1.4"},
+		{CertFile: "/missing/client.pem"},
+		{CertFile: "/missing/client.pem", KeyFile: "/missing/client.key"},
+		{CaFile: "/missing/ca.pem"},
+	}
+	for _, config := range invalid {
+		assert.Error(t, config.Validate())
+	}
+}